@@ -11,4 +11,6 @@ CREATE TABLE customers_tokens
     customer_id bigint not null references customers,
     expire timestamp not null default CURRENT_TIMESTAMP + INTERVAL '1 hour',
     created timestamp not null default CURRENT_TIMESTAMP
-);
\ No newline at end of file
+);
+
+-- таблицы приложения создаются миграциями из pkg/migrations/sql
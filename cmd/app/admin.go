@@ -0,0 +1,293 @@
+package app
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/az1zcheckit/crud/pkg/accesslog"
+	"github.com/az1zcheckit/crud/pkg/customers"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/security"
+	"github.com/gorilla/mux"
+)
+
+//go:embed admin
+var adminFiles embed.FS
+
+const (
+	// adminSessionCookie - cookie сессии менеджера; входит в список cookie, которые проверяет CSRF.
+	adminSessionCookie = "session"
+	// adminPageSize - покупателей на странице списка.
+	adminPageSize = 20
+)
+
+// adminTemplates - страницы веб-интерфейса, каждая вместе с общим шаблоном layout.html.
+var adminTemplates = func() map[string]*template.Template {
+	pages := make(map[string]*template.Template)
+	for _, name := range []string{"login.html", "customers.html", "customer.html"} {
+		pages[name] = template.Must(template.ParseFS(adminFiles, "admin/layout.html", "admin/"+name))
+	}
+	return pages
+}()
+
+// adminView - данные страницы веб-интерфейса.
+type adminView struct {
+	Title   string
+	Manager *security.Managers
+	CSRF    string
+	Flash   *flash
+	Error   string
+	Login   string
+
+	Query     string
+	Customers []*customers.Customer
+	Total     int64
+	Page      int
+	Pages     int
+	Prev      string
+	Next      string
+	Customer  *customers.Customer
+}
+
+// initAdmin регистрирует веб-интерфейс менеджеров.
+func (s *Server) initAdmin() {
+	s.mux.HandleFunc("/admin/", s.adminOnly(s.handleAdminIndex)).Methods(GET)
+	s.mux.HandleFunc("/admin/login", s.handleAdminLoginForm).Methods(GET)
+	s.mux.HandleFunc("/admin/login", s.handleAdminLogin).Methods(POST)
+	s.mux.HandleFunc("/admin/logout", s.adminOnly(s.handleAdminLogout)).Methods(POST)
+	s.mux.HandleFunc("/admin/customers", s.adminOnly(s.handleAdminCustomers)).Methods(GET)
+	s.mux.HandleFunc("/admin/customers/{id}", s.adminOnly(s.handleAdminCustomer)).Methods(GET)
+	s.mux.HandleFunc("/admin/customers/{id}/{action}", s.adminOnly(s.handleAdminCustomerAction)).Methods(POST)
+	s.mux.PathPrefix("/admin/static/").Handler(adminStaticHandler()).Methods(GET)
+}
+
+// adminStaticHandler отдаёт стили веб-интерфейса.
+func adminStaticHandler() http.Handler {
+	files, err := fs.Sub(adminFiles, "admin/static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/admin/static", http.FileServer(http.FS(files)))
+}
+
+// adminHandler - обработчик страницы, доступной только после входа.
+type adminHandler func(writer http.ResponseWriter, request *http.Request, manager *security.Managers)
+
+// adminOnly пропускает к handler только запросы с действующей сессией менеджера,
+// остальных отправляет на страницу входа.
+func (s *Server) adminOnly(handler adminHandler) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		cookie, err := request.Cookie(adminSessionCookie)
+		if err != nil {
+			http.Redirect(writer, request, "/admin/login", http.StatusSeeOther)
+			return
+		}
+		manager, err := s.securitySvc.ManagerBySession(request.Context(), cookie.Value)
+		if errors.Is(err, security.ErrNoSuchUser) || errors.Is(err, security.ErrExpiredToken) {
+			clearCookie(writer, request, adminSessionCookie, "/admin")
+			setFlash(writer, request, "error", "Сессия истекла, войдите снова")
+			http.Redirect(writer, request, "/admin/login", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			logger.Ctx(request.Context()).Error(err)
+			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		accesslog.SetPrincipal(request.Context(), "manager:"+manager.Login)
+		handler(writer, request, manager)
+	}
+}
+
+// render выводит страницу веб-интерфейса name.
+func (s *Server) render(writer http.ResponseWriter, request *http.Request, code int, name string, view *adminView) {
+	view.CSRF = s.csrfToken(request)
+	view.Flash = popFlash(writer, request)
+	renderPage(writer, request, code, adminTemplates[name], view)
+}
+
+func (s *Server) handleAdminIndex(writer http.ResponseWriter, request *http.Request, manager *security.Managers) {
+	http.Redirect(writer, request, "/admin/customers", http.StatusSeeOther)
+}
+
+func (s *Server) handleAdminLoginForm(writer http.ResponseWriter, request *http.Request) {
+	s.render(writer, request, http.StatusOK, "login.html", &adminView{Title: "Вход"})
+}
+
+func (s *Server) handleAdminLogin(writer http.ResponseWriter, request *http.Request) {
+	login := strings.TrimSpace(request.PostFormValue("login"))
+	token, err := s.securitySvc.LoginManager(request.Context(), login, request.PostFormValue("password"))
+	if errors.Is(err, security.ErrNoSuchUser) || errors.Is(err, security.ErrInvalidPassword) {
+		logger.Ctx(request.Context()).Warnw("failed manager login", "login", login)
+		s.render(writer, request, http.StatusUnauthorized, "login.html", &adminView{
+			Title: "Вход",
+			Login: login,
+			Error: "Неверный логин или пароль",
+		})
+		return
+	}
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(writer, &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    token,
+		Path:     "/admin",
+		MaxAge:   int(security.SessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	setFlash(writer, request, "ok", "Вы вошли как "+login)
+	http.Redirect(writer, request, "/admin/customers", http.StatusSeeOther)
+}
+
+func (s *Server) handleAdminLogout(writer http.ResponseWriter, request *http.Request, manager *security.Managers) {
+	cookie, err := request.Cookie(adminSessionCookie)
+	if err == nil {
+		err = s.securitySvc.LogoutManager(request.Context(), cookie.Value)
+	}
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+	}
+	clearCookie(writer, request, adminSessionCookie, "/admin")
+	setFlash(writer, request, "ok", "Вы вышли")
+	http.Redirect(writer, request, "/admin/login", http.StatusSeeOther)
+}
+
+// handleAdminCustomers - список покупателей с поиском по имени и телефону.
+func (s *Server) handleAdminCustomers(writer http.ResponseWriter, request *http.Request, manager *security.Managers) {
+	query := strings.TrimSpace(request.URL.Query().Get("q"))
+	page, err := strconv.Atoi(request.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	items, total, err := s.customersSvc.Search(request.Context(), query, adminPageSize, (page-1)*adminPageSize)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	pages := int((total + adminPageSize - 1) / adminPageSize)
+	if pages == 0 {
+		pages = 1
+	}
+	view := &adminView{
+		Title:     "Покупатели",
+		Manager:   manager,
+		Query:     query,
+		Customers: items,
+		Total:     total,
+		Page:      page,
+		Pages:     pages,
+	}
+	if page > 1 {
+		view.Prev = customersPageURL(query, page-1)
+	}
+	if page < pages {
+		view.Next = customersPageURL(query, page+1)
+	}
+	s.render(writer, request, http.StatusOK, "customers.html", view)
+}
+
+// customersPageURL - адрес страницы page списка покупателей с поиском query.
+func customersPageURL(query string, page int) string {
+	values := url.Values{}
+	if query != "" {
+		values.Set("q", query)
+	}
+	values.Set("page", strconv.Itoa(page))
+	return "/admin/customers?" + values.Encode()
+}
+
+// handleAdminCustomer - карточка покупателя с действиями.
+func (s *Server) handleAdminCustomer(writer http.ResponseWriter, request *http.Request, manager *security.Managers) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	item, err := s.customersSvc.ByID(request.Context(), id)
+	if errors.Is(err, customers.ErrNotFound) {
+		setFlash(writer, request, "error", "Покупатель не найден")
+		http.Redirect(writer, request, "/admin/customers", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	s.render(writer, request, http.StatusOK, "customer.html", &adminView{
+		Title:    item.Name,
+		Manager:  manager,
+		Customer: item,
+	})
+}
+
+// handleAdminCustomerAction блокирует, разблокирует или удаляет покупателя так же, как
+// REST-маршруты и массовые операции: с отзывом токенов и записью в журнал от имени менеджера.
+func (s *Server) handleAdminCustomerAction(writer http.ResponseWriter, request *http.Request, manager *security.Managers) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	back := "/admin/customers/" + strconv.FormatInt(id, 10)
+
+	var message string
+	switch mux.Vars(request)["action"] {
+	case "block":
+		err = s.customersSvc.BlockByID(request.Context(), id, "manager:"+manager.Login)
+		if err == nil {
+			s.metrics.CustomersBlocked.Inc()
+		}
+		message = "Покупатель заблокирован"
+	case "unblock":
+		err = s.customersSvc.UnBlockByID(request.Context(), id, "manager:"+manager.Login)
+		message = "Покупатель разблокирован"
+	case "delete":
+		if request.PostFormValue("confirm") == "" {
+			setFlash(writer, request, "error", "Подтвердите удаление")
+			http.Redirect(writer, request, back, http.StatusSeeOther)
+			return
+		}
+		err = s.customersSvc.RemoveByID(request.Context(), id, "manager:"+manager.Login)
+		message = "Покупатель удалён"
+		back = "/admin/customers"
+	default:
+		http.NotFound(writer, request)
+		return
+	}
+	if errors.Is(err, customers.ErrNotFound) {
+		setFlash(writer, request, "error", "Покупатель не найден")
+		http.Redirect(writer, request, "/admin/customers", http.StatusSeeOther)
+		return
+	}
+	if errors.Is(err, customers.ErrReferenced) {
+		logger.Ctx(request.Context()).Warn(err)
+		setFlash(writer, request, "error", "Покупателя нельзя удалить: у него есть карты, кредиты или продажи")
+		http.Redirect(writer, request, "/admin/customers/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		setFlash(writer, request, "error", "Не удалось выполнить действие, попробуйте позже")
+		http.Redirect(writer, request, back, http.StatusSeeOther)
+		return
+	}
+	logger.Ctx(request.Context()).Infow("customer changed from admin", "customer_id", id, "action", mux.Vars(request)["action"], "manager_id", manager.ID)
+	setFlash(writer, request, "ok", message)
+	http.Redirect(writer, request, back, http.StatusSeeOther)
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/az1zcheckit/crud/pkg/binding"
+	"github.com/az1zcheckit/crud/pkg/customers"
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+// BulkRequest - покупатели для массовой операции, если они не заданы фильтром в строке запроса.
+type BulkRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// handleBulkCustomers возвращает обработчик массовой операции action над покупателями
+// из тела запроса (ids) или подходящими под фильтр списка (q, active, created_from, created_to).
+// Операцию выполняет менеджер, прошедший аутентификацию; он записывается в журнал.
+func (s *Server) handleBulkCustomers(action string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		manager, ok := requestManager(writer, request)
+		if !ok {
+			return
+		}
+		var body BulkRequest
+		if request.ContentLength != 0 {
+			err := binding.Decode(request, &body)
+			if err != nil {
+				respondDecodeError(writer, request, err)
+				return
+			}
+		}
+		filter, err := customersFilter(request)
+		if err != nil {
+			logger.Ctx(request.Context()).Warn(err)
+			respondFail(writer, request, http.StatusBadRequest, err.Error())
+			return
+		}
+		var target *customers.Filter
+		if !filter.IsZero() {
+			target = &filter
+		}
+
+		actor := "manager:" + manager.Login
+		report, err := s.customersSvc.Bulk(request.Context(), action, body.IDs, target, actor)
+		if errors.Is(err, customers.ErrInvalidBulk) || errors.Is(err, customers.ErrBulkTooLarge) {
+			logger.Ctx(request.Context()).Warn(err)
+			respondFail(writer, request, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			logger.Ctx(request.Context()).Error(err)
+			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if action == customers.BulkBlock {
+			s.metrics.CustomersBlocked.Add(float64(report.Changed))
+		}
+		logger.Ctx(request.Context()).Infow("customers changed in bulk", "action", action, "actor", actor,
+			"total", report.Total, "changed", report.Changed)
+		respond(writer, request, http.StatusOK, report)
+	}
+}
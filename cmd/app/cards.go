@@ -0,0 +1,195 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/az1zcheckit/crud/cmd/app/middleware"
+	"github.com/az1zcheckit/crud/pkg/binding"
+	"github.com/az1zcheckit/crud/pkg/cards"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/money"
+)
+
+// CardIssue - тело запроса на выпуск карты (может отсутствовать).
+type CardIssue struct {
+	Currency string `json:"currency"`
+}
+
+// CardLimit - тело запроса на изменение дневного лимита карты.
+type CardLimit struct {
+	DailyLimit int64 `json:"dailyLimit"`
+}
+
+// CardTransfer - тело запроса на перевод с карты на карту.
+type CardTransfer struct {
+	From   int64 `json:"from"`
+	To     int64 `json:"to"`
+	Amount int64 `json:"amount"`
+}
+
+// cardsErrorCode сопоставляет ошибку сервиса карт с HTTP-кодом ответа.
+func cardsErrorCode(err error) int {
+	switch {
+	case errors.Is(err, cards.ErrNotFound), errors.Is(err, cards.ErrNoSuchCustomer):
+		return http.StatusNotFound
+	case errors.Is(err, cards.ErrCustomerBlocked), errors.Is(err, cards.ErrCardBlocked),
+		errors.Is(err, cards.ErrCardExpired), errors.Is(err, cards.ErrInvalidAmount),
+		errors.Is(err, cards.ErrNotEnoughBalance), errors.Is(err, cards.ErrLimitExceeded),
+		errors.Is(err, cards.ErrNoRate), errors.Is(err, money.ErrUnknownCurrency):
+		return http.StatusBadRequest
+	case errors.Is(err, cards.ErrNotVerified), errors.Is(err, cards.ErrNotOwner):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// respondCardsError отправляет ответ, соответствующий ошибке сервиса карт.
+func respondCardsError(writer http.ResponseWriter, request *http.Request, err error) {
+	code := cardsErrorCode(err)
+	if code == http.StatusInternalServerError {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(code), code)
+		return
+	}
+	respondFail(writer, request, code, err.Error())
+}
+
+// handleIssueCard - выпускает карту покупателю.
+func (s *Server) handleIssueCard(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var issue CardIssue
+	err = binding.Decode(request, &issue)
+	if err != nil && err != io.EOF {
+		respondDecodeError(writer, request, err)
+		return
+	}
+
+	item, err := s.cardsSvc.Issue(request.Context(), id, issue.Currency)
+	if err != nil {
+		respondCardsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusCreated, item)
+}
+
+// handleGetCustomerCards - все карты покупателя.
+func (s *Server) handleGetCustomerCards(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.cardsSvc.ByCustomer(request.Context(), id)
+	if err != nil {
+		respondCardsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleGetCardByID - нахождение карты по id.
+func (s *Server) handleGetCardByID(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.cardsSvc.ByID(request.Context(), id)
+	if err != nil {
+		respondCardsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
+
+// handleSetCardLimit - изменяет дневной лимит расходов по карте (только менеджер).
+func (s *Server) handleSetCardLimit(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var limit CardLimit
+	err = binding.Decode(request, &limit)
+	if err != nil {
+		respondDecodeError(writer, request, err)
+		return
+	}
+
+	item, err := s.cardsSvc.SetDailyLimit(request.Context(), id, limit.DailyLimit)
+	if err != nil {
+		respondCardsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
+
+// handleBlockCard - блокирует карту.
+func (s *Server) handleBlockCard(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err = s.cardsSvc.BlockByID(request.Context(), id)
+	if err != nil {
+		respondCardsError(writer, request, err)
+		return
+	}
+}
+
+// handleUnBlockCard - разблокирует карту (только менеджер).
+func (s *Server) handleUnBlockCard(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err = s.cardsSvc.UnBlockByID(request.Context(), id)
+	if err != nil {
+		respondCardsError(writer, request, err)
+		return
+	}
+}
+
+// handleTransfer - перевод с карты на карту с учётом дневного лимита.
+// Переводит только владелец карты from, опознанный по токену.
+func (s *Server) handleTransfer(writer http.ResponseWriter, request *http.Request) {
+	customerID, ok := middleware.CustomerFromContext(request.Context())
+	if !ok {
+		logger.Ctx(request.Context()).Warn("customer is not authenticated")
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var transfer CardTransfer
+	err := binding.Decode(request, &transfer)
+	if err != nil {
+		respondDecodeError(writer, request, err)
+		return
+	}
+
+	item, err := s.cardsSvc.Transfer(request.Context(), customerID, transfer.From, transfer.To, transfer.Amount)
+	if err != nil {
+		respondCardsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
@@ -0,0 +1,211 @@
+// Страница документации: строит список операций по /openapi.json
+// и позволяет отправить запрос прямо со страницы.
+(function () {
+    'use strict';
+
+    var spec;
+
+    function el(tag, attrs, children) {
+        var node = document.createElement(tag);
+        Object.keys(attrs || {}).forEach(function (key) {
+            if (key === 'text') {
+                node.textContent = attrs[key];
+            } else {
+                node.setAttribute(key, attrs[key]);
+            }
+        });
+        (children || []).forEach(function (child) {
+            node.appendChild(child);
+        });
+        return node;
+    }
+
+    // example строит пример значения по схеме, раскрывая $ref из components.
+    function example(schema, depth) {
+        if (!schema || depth > 5) {
+            return null;
+        }
+        if (schema.$ref) {
+            return example(spec.components.schemas[schema.$ref.split('/').pop()], depth + 1);
+        }
+        switch (schema.type) {
+        case 'object':
+            var result = {};
+            Object.keys(schema.properties || {}).forEach(function (name) {
+                result[name] = example(schema.properties[name], depth + 1);
+            });
+            return result;
+        case 'array':
+            return [example(schema.items, depth + 1)];
+        case 'integer':
+        case 'number':
+            return 0;
+        case 'boolean':
+            return false;
+        case 'string':
+            if (schema.enum) {
+                return schema.enum[0];
+            }
+            if (schema.format === 'date-time') {
+                return new Date().toISOString();
+            }
+            if (schema.format === 'date') {
+                return new Date().toISOString().slice(0, 10);
+            }
+            return '';
+        }
+        return null;
+    }
+
+    function send(method, path, op, form, output) {
+        var url = path;
+        var query = new URLSearchParams();
+        (op.parameters || []).forEach(function (param) {
+            var value = form.elements['param-' + param.in + '-' + param.name].value;
+            if (param.in === 'path') {
+                url = url.replace('{' + param.name + '}', encodeURIComponent(value));
+            } else if (param.in === 'query' && value !== '') {
+                query.append(param.name, value);
+            }
+        });
+        if (query.toString()) {
+            url += '?' + query.toString();
+        }
+
+        var init = {method: method.toUpperCase(), headers: {}};
+        var body = form.elements.body;
+        if (body) {
+            if (body.type === 'file') {
+                var data = new FormData();
+                Array.prototype.forEach.call(form.querySelectorAll('[data-field]'), function (input) {
+                    if (input.type === 'file') {
+                        if (input.files[0]) {
+                            data.append(input.dataset.field, input.files[0]);
+                        }
+                    } else {
+                        data.append(input.dataset.field, input.value);
+                    }
+                });
+                init.body = data;
+            } else {
+                init.headers['Content-Type'] = 'application/json';
+                init.body = body.value;
+            }
+        }
+
+        output.textContent = init.method + ' ' + url + '\n…';
+        fetch(url, init).then(function (response) {
+            return response.text().then(function (text) {
+                try {
+                    text = JSON.stringify(JSON.parse(text), null, 2);
+                } catch (e) {
+                    // не JSON - показываем как есть
+                }
+                output.textContent = init.method + ' ' + url + '\n' + response.status + ' ' + response.statusText + '\n\n' + text;
+            });
+        }).catch(function (err) {
+            output.textContent = String(err);
+        });
+    }
+
+    function operation(method, path, op) {
+        var form = el('form');
+        (op.parameters || []).forEach(function (param) {
+            form.appendChild(el('label', {title: param.description || ''}, [
+                el('span', {text: param.name + (param.required ? '*' : '')}),
+                el('input', {name: 'param-' + param.in + '-' + param.name, placeholder: param.in + (param.description ? ': ' + param.description : '')})
+            ]));
+        });
+
+        var content = op.requestBody ? op.requestBody.content : {};
+        // JSON, если операция его принимает; форма - для загрузки файлов
+        if (content['application/json']) {
+            var textarea = el('textarea', {name: 'body'});
+            textarea.value = JSON.stringify(example(content['application/json'].schema, 0), null, 2);
+            form.appendChild(textarea);
+        } else if (content['multipart/form-data']) {
+            var fields = content['multipart/form-data'].schema.properties;
+            Object.keys(fields).forEach(function (name) {
+                var file = fields[name].format === 'binary';
+                var input = el('input', {type: file ? 'file' : 'text', 'data-field': name});
+                if (file) {
+                    input.name = 'body';
+                }
+                form.appendChild(el('label', {}, [el('span', {text: name}), input]));
+            });
+        }
+
+        var output = el('pre');
+        form.appendChild(el('button', {type: 'submit', text: 'Отправить'}));
+        form.addEventListener('submit', function (event) {
+            event.preventDefault();
+            send(method, path, op, form, output);
+        });
+
+        var responses = Object.keys(op.responses).map(function (code) {
+            var response = op.responses[code];
+            var text = code + ' - ' + response.description;
+            if (response.content && response.content['application/json']) {
+                text += '\n' + JSON.stringify(example(response.content['application/json'].schema, 0), null, 2);
+            }
+            return el('pre', {text: text});
+        });
+
+        var summary = el('summary', {}, [
+            el('span', {'class': 'method ' + method, text: method}),
+            el('span', {'class': 'path', text: path}),
+            el('span', {text: ' - ' + (op.summary || '')})
+        ]);
+        var body = el('div', {'class': 'body'}, [el('p', {text: op.description || ''}), form, output].concat(responses));
+        var node = el('details', {'class': op.deprecated ? 'deprecated' : ''}, [summary, body]);
+        node.dataset.search = (method + ' ' + path + ' ' + (op.summary || '')).toLowerCase();
+        return node;
+    }
+
+    function render() {
+        document.title = spec.info.title;
+        document.getElementById('title').textContent = spec.info.title + ' ' + spec.info.version;
+        document.getElementById('description').textContent = spec.info.description || '';
+
+        var groups = {};
+        Object.keys(spec.paths).sort().forEach(function (path) {
+            Object.keys(spec.paths[path]).forEach(function (method) {
+                var op = spec.paths[path][method];
+                var tag = (op.tags || ['other'])[0];
+                (groups[tag] = groups[tag] || []).push(operation(method, path, op));
+            });
+        });
+
+        var root = document.getElementById('operations');
+        (spec.tags || []).map(function (tag) {
+            return tag.name;
+        }).concat(Object.keys(groups)).forEach(function (tag) {
+            if (!groups[tag]) {
+                return;
+            }
+            var description = (spec.tags || []).filter(function (item) {
+                return item.name === tag;
+            }).map(function (item) {
+                return item.description;
+            })[0];
+            root.appendChild(el('section', {}, [el('h2', {text: description || tag})].concat(groups[tag])));
+            delete groups[tag];
+        });
+    }
+
+    document.getElementById('filter').addEventListener('input', function (event) {
+        var value = event.target.value.toLowerCase();
+        Array.prototype.forEach.call(document.querySelectorAll('details'), function (node) {
+            node.hidden = node.dataset.search.indexOf(value) === -1;
+        });
+    });
+
+    fetch('/openapi.json').then(function (response) {
+        return response.json();
+    }).then(function (data) {
+        spec = data;
+        render();
+    }).catch(function (err) {
+        document.getElementById('operations').textContent = String(err);
+    });
+})();
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/customers"
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+// customersFilter читает фильтр списка покупателей из строки запроса:
+// q, active, created_from и created_to (YYYY-MM-DD, включительно).
+func customersFilter(request *http.Request) (customers.Filter, error) {
+	query := request.URL.Query()
+	return customers.ParseFilter(query.Get("q"), query.Get("active"), query.Get("created_from"), query.Get("created_to"))
+}
+
+// handleExportCustomers выгружает покупателей (format=csv|jsonl|xlsx, по умолчанию csv)
+// с фильтрами списка. Ответ отправляется по мере чтения строк из базы.
+// Выгрузку получает только менеджер, он записывается в журнал.
+func (s *Server) handleExportCustomers(writer http.ResponseWriter, request *http.Request) {
+	manager, ok := requestManager(writer, request)
+	if !ok {
+		return
+	}
+	format := request.URL.Query().Get("format")
+	if format == "" {
+		format = customers.FormatCSV
+	}
+	contentType, ok := customers.ExportFormats[format]
+	if !ok {
+		logger.Ctx(request.Context()).Warnw("unknown export format", "format", format)
+		respondFail(writer, request, http.StatusBadRequest, customers.ErrUnknownFormat.Error())
+		return
+	}
+	filter, err := customersFilter(request)
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		respondFail(writer, request, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := "customers-" + time.Now().Format("20060102-150405") + "." + format
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	writer.Header().Set("Cache-Control", "no-store")
+	out := &countingWriter{Writer: writer}
+	count, err := s.customersSvc.Export(request.Context(), out, format, filter)
+	if err != nil && out.n == 0 {
+		// клиенту ещё ничего не отправлено - можно ответить ошибкой
+		logger.Ctx(request.Context()).Error(err)
+		writer.Header().Del("Content-Disposition")
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// оборванное соединение, чтобы клиент не принял неполный файл за целый
+		logger.Ctx(request.Context()).Errorw("customers export interrupted", "error", err, "exported", count, "manager", manager.Login)
+		panic(http.ErrAbortHandler)
+	}
+	logger.Ctx(request.Context()).Infow("customers exported", "format", format, "count", count,
+		"manager", manager.Login, "manager_id", manager.ID)
+}
+
+// countingWriter считает байты, записанные в Writer.
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/az1zcheckit/crud/pkg/health"
+	"github.com/az1zcheckit/crud/pkg/version"
+)
+
+// Health - ответ проверки того, что процесс жив.
+type Health struct {
+	Status string `json:"status"`
+}
+
+// handleHealth - процесс жив и обрабатывает запросы; зависимости не проверяются.
+func (s *Server) handleHealth(writer http.ResponseWriter, request *http.Request) {
+	respond(writer, request, http.StatusOK, &Health{Status: health.StatusOK})
+}
+
+// handleReady - готовность принимать трафик: 503 с подробностями, если хоть одна проверка не прошла.
+func (s *Server) handleReady(writer http.ResponseWriter, request *http.Request) {
+	report := s.checker.Run(request.Context())
+	code := http.StatusOK
+	if report.Status != health.StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writer.Header().Set("Cache-Control", "no-store")
+	respond(writer, request, code, report)
+}
+
+// handleVersion - информация о сборке.
+func (s *Server) handleVersion(writer http.ResponseWriter, request *http.Request) {
+	respond(writer, request, http.StatusOK, version.Get())
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/az1zcheckit/crud/pkg/binding"
+	"github.com/az1zcheckit/crud/pkg/customers"
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+// mimeCSV - Content-Type файла импорта, переданного телом запроса.
+const mimeCSV = "text/csv"
+
+// handleImportCustomers загружает покупателей из CSV: телом запроса (text/csv)
+// или полем file формы multipart/form-data. С dry_run=true только проверяет файл.
+// Если в файле есть ошибочные строки, отвечает 422 с отчётом и ничего не записывает.
+// Импортирует только менеджер, изменения записываются в журнал от его имени.
+func (s *Server) handleImportCustomers(writer http.ResponseWriter, request *http.Request) {
+	manager, ok := requestManager(writer, request)
+	if !ok {
+		return
+	}
+	opts := customers.ImportOptions{Actor: "manager:" + manager.Login}
+	if value := request.URL.Query().Get("dry_run"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			logger.Ctx(request.Context()).Warn(err)
+			respondFail(writer, request, http.StatusBadRequest, "invalid dry_run")
+			return
+		}
+		opts.DryRun = dryRun
+	}
+
+	request.Body = http.MaxBytesReader(writer, request.Body, customers.MaxImportSize+1<<20)
+	var file io.Reader = request.Body
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	switch {
+	case err == nil && mediaType == binding.MIMEMultipart:
+		part, _, err := request.FormFile("file")
+		if err != nil {
+			logger.Ctx(request.Context()).Warn(err)
+			http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		defer part.Close()
+		file = part
+	case err == nil && mediaType == mimeCSV:
+	default:
+		logger.Ctx(request.Context()).Warnw("unsupported import content type", "content_type", request.Header.Get("Content-Type"))
+		http.Error(writer, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	report, err := s.customersSvc.Import(request.Context(), file, opts)
+	if errors.Is(err, customers.ErrInvalidImport) {
+		logger.Ctx(request.Context()).Warn(err)
+		respondFail(writer, request, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	code := http.StatusOK
+	if len(report.Errors) > 0 {
+		code = http.StatusUnprocessableEntity
+	}
+	logger.Ctx(request.Context()).Infow("customers imported", "dry_run", report.DryRun, "rows", report.Rows,
+		"inserted", report.Inserted, "updated", report.Updated, "errors", len(report.Errors), "actor", opts.Actor)
+	respond(writer, request, code, report)
+}
@@ -0,0 +1,214 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/az1zcheckit/crud/pkg/binding"
+	"github.com/az1zcheckit/crud/pkg/kyc"
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+// KYCDecision - тело запроса на подтверждение или отклонение профиля.
+// Решение принимает менеджер, прошедший аутентификацию.
+type KYCDecision struct {
+	Reason string `json:"reason"`
+}
+
+// respondKYCError отправляет ответ, соответствующий ошибке сервиса проверки покупателей.
+func respondKYCError(writer http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, kyc.ErrNotFound), errors.Is(err, kyc.ErrNoSuchCustomer),
+		errors.Is(err, kyc.ErrNoSuchManager), errors.Is(err, kyc.ErrDocumentNotFound):
+		respondFail(writer, request, http.StatusNotFound, err.Error())
+	case errors.Is(err, kyc.ErrInvalidProfile), errors.Is(err, kyc.ErrInvalidDocument):
+		respondFail(writer, request, http.StatusBadRequest, err.Error())
+	case errors.Is(err, kyc.ErrDuplicateNationalID), errors.Is(err, kyc.ErrInvalidStatus):
+		respondFail(writer, request, http.StatusConflict, err.Error())
+	case errors.Is(err, kyc.ErrDocumentTooLarge):
+		respondFail(writer, request, http.StatusRequestEntityTooLarge, err.Error())
+	default:
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// handleGetProfile - профиль покупателя и статус проверки.
+func (s *Server) handleGetProfile(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.kycSvc.ByCustomer(request.Context(), id)
+	if err != nil {
+		respondKYCError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
+
+// handleSaveProfile - создаёт или изменяет профиль покупателя; доступно самому покупателю и менеджеру.
+func (s *Server) handleSaveProfile(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if !requestOwner(writer, request, id) {
+		return
+	}
+
+	var item *kyc.Profile
+	err = binding.Decode(request, &item)
+	if err != nil || item == nil {
+		respondDecodeError(writer, request, err)
+		return
+	}
+	item.CustomerID = id
+
+	profile, err := s.kycSvc.Save(request.Context(), item)
+	if err != nil {
+		respondKYCError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, profile)
+}
+
+// handleGetPendingProfiles - профили, ожидающие проверки менеджером.
+func (s *Server) handleGetPendingProfiles(writer http.ResponseWriter, request *http.Request) {
+	items, err := s.kycSvc.Pending(request.Context())
+	if err != nil {
+		respondKYCError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleVerifyProfile - подтверждает профиль покупателя.
+func (s *Server) handleVerifyProfile(writer http.ResponseWriter, request *http.Request) {
+	s.handleKYCDecision(writer, request, true)
+}
+
+// handleRejectProfile - отклоняет профиль покупателя.
+func (s *Server) handleRejectProfile(writer http.ResponseWriter, request *http.Request) {
+	s.handleKYCDecision(writer, request, false)
+}
+
+func (s *Server) handleKYCDecision(writer http.ResponseWriter, request *http.Request, verify bool) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	manager, ok := requestManager(writer, request)
+	if !ok {
+		return
+	}
+	var decision KYCDecision
+	if request.ContentLength != 0 {
+		err = binding.Decode(request, &decision)
+		if err != nil {
+			respondDecodeError(writer, request, err)
+			return
+		}
+	}
+
+	var item *kyc.Profile
+	if verify {
+		item, err = s.kycSvc.Verify(request.Context(), id, manager.ID)
+	} else {
+		item, err = s.kycSvc.Reject(request.Context(), id, manager.ID, decision.Reason)
+	}
+	if err != nil {
+		respondKYCError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
+
+// handleAddDocument - загружает скан документа (multipart/form-data, поля file и kind);
+// доступно самому покупателю и менеджеру.
+func (s *Server) handleAddDocument(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if !requestOwner(writer, request, id) {
+		return
+	}
+
+	request.Body = http.MaxBytesReader(writer, request.Body, kyc.MaxDocumentSize+1<<20)
+	file, header, err := request.FormFile("file")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	item, err := s.kycSvc.AddDocument(request.Context(), id, request.FormValue("kind"), header.Filename, file)
+	if err != nil {
+		respondKYCError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusCreated, item)
+}
+
+// handleGetDocuments - документы покупателя.
+func (s *Server) handleGetDocuments(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.kycSvc.Documents(request.Context(), id)
+	if err != nil {
+		respondKYCError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleGetDocument - скачивание документа покупателя.
+func (s *Server) handleGetDocument(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	documentID, err := idFromRequest(request, "documentId")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, file, err := s.kycSvc.OpenDocument(request.Context(), id, documentID)
+	if err != nil {
+		respondKYCError(writer, request, err)
+		return
+	}
+	defer file.Close()
+
+	writer.Header().Set("Content-Type", item.ContentType)
+	writer.Header().Set("Content-Length", strconv.FormatInt(item.Size, 10))
+	writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": item.Name}))
+	_, err = io.Copy(writer, file)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// LegacyOptions - устаревшие маршруты: прежние пути без версии
+// и "точечные" маршруты вида /customers.getAll, которые использует web/index.html.
+// Ответы на них содержат заголовки Deprecation, Sunset и Link на замену в /api/v1.
+type LegacyOptions struct {
+	Enabled bool
+	// Deprecated - когда маршруты объявлены устаревшими.
+	Deprecated time.Time
+	// Sunset - когда их планируется отключить; нулевое значение - дата не назначена.
+	Sunset time.Time
+}
+
+// initLegacy регистрирует прежние пути маршрутов routes и "точечные" маршруты.
+// Маршруты без прежнего пути пропускаются. Изменяющие "точечные" маршруты принимают
+// только POST: GET не проверяется на CSRF, и ссылка на чужой странице могла бы их вызвать.
+func (s *Server) initLegacy(routes []route) {
+	s.legacy = make(map[string]string)
+	for _, route := range routes {
+		if route.legacy == "" {
+			continue
+		}
+		successor := apiPrefix + route.path
+		s.legacy[route.method+" "+route.legacy] = route.method + " " + successor
+		s.mux.Handle(route.legacy, s.deprecated(successor, route.handler)).Methods(route.method)
+	}
+
+	s.mux.Handle("/customers.getAll", s.deprecated(apiPrefix+"/customers", http.HandlerFunc(s.handleGetAllCustomers))).Methods(GET)
+	s.mux.Handle("/customers.getAllActive", s.deprecated(apiPrefix+"/customers/active", http.HandlerFunc(s.handleGetAllActiveCustomers))).Methods(GET)
+	s.mux.Handle("/customers.getById", withQueryID(s.deprecated(apiPrefix+"/customers/{id}", http.HandlerFunc(s.handleGetCustomersByID)))).Methods(GET)
+	s.mux.Handle("/customers.save", s.deprecated(apiPrefix+"/customers", http.HandlerFunc(s.handleSaveCustomers))).Methods(POST)
+	s.mux.Handle("/customers.removeById", withQueryID(s.deprecated(apiPrefix+"/customers/{id}", s.managerOnly(s.handleRemoveByID)))).Methods(POST)
+	s.mux.Handle("/customers.blockById", withQueryID(s.deprecated(apiPrefix+"/customers/{id}/block", s.managerOnly(s.handleBlockByID)))).Methods(POST)
+	s.mux.Handle("/customers.unblockById", withQueryID(s.deprecated(apiPrefix+"/customers/{id}/block", s.managerOnly(s.handleUnBlockByID)))).Methods(POST)
+}
+
+// deprecated добавляет к ответу заголовки устаревшего маршрута (RFC 9745, RFC 8594)
+// со ссылкой на successor и считает обращения в метрике crud_http_legacy_requests_total.
+func (s *Server) deprecated(successor string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(request); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		s.metrics.LegacyRequests.WithLabelValues(route).Inc()
+
+		header := writer.Header()
+		header.Set("Deprecation", "@"+strconv.FormatInt(s.legacyOpts.Deprecated.Unix(), 10))
+		if !s.legacyOpts.Sunset.IsZero() {
+			header.Set("Sunset", s.legacyOpts.Sunset.UTC().Format(http.TimeFormat))
+		}
+		header.Set("Link", "<"+expandPath(successor, mux.Vars(request))+`>; rel="successor-version"`)
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+// expandPath подставляет значения параметров в шаблон пути.
+func expandPath(template string, vars map[string]string) string {
+	for name, value := range vars {
+		template = strings.ReplaceAll(template, "{"+name+"}", value)
+	}
+	return template
+}
+
+// withQueryID переносит id из строки запроса или формы в параметры пути,
+// чтобы "точечные" маршруты (/customers.getById?id=1) обслуживались обработчиками /api/v1.
+func withQueryID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		id := request.FormValue("id")
+		if id == "" {
+			http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		handler.ServeHTTP(writer, mux.SetURLVars(request, map[string]string{"id": id}))
+	})
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/az1zcheckit/crud/pkg/binding"
+	"github.com/az1zcheckit/crud/pkg/loans"
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+// LoanDecision - тело запроса на одобрение или отклонение заявки.
+// Решение принимает менеджер, прошедший аутентификацию.
+type LoanDecision struct {
+	Reason string `json:"reason"`
+}
+
+// respondLoansError отправляет ответ, соответствующий ошибке сервиса кредитов.
+func respondLoansError(writer http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, loans.ErrNotFound), errors.Is(err, loans.ErrNoSuchCustomer), errors.Is(err, loans.ErrNoSuchManager):
+		respondFail(writer, request, http.StatusNotFound, err.Error())
+	case errors.Is(err, loans.ErrInvalidTerms), errors.Is(err, loans.ErrCustomerBlocked):
+		respondFail(writer, request, http.StatusBadRequest, err.Error())
+	case errors.Is(err, loans.ErrInvalidStatus):
+		respondFail(writer, request, http.StatusConflict, err.Error())
+	default:
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// handleSaveLoan - создаёт или изменяет черновик заявки на кредит.
+func (s *Server) handleSaveLoan(writer http.ResponseWriter, request *http.Request) {
+	var item *loans.Loan
+	err := binding.Decode(request, &item)
+	if err != nil || item == nil {
+		respondDecodeError(writer, request, err)
+		return
+	}
+
+	loan, err := s.loansSvc.Save(request.Context(), item)
+	if err != nil {
+		respondLoansError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, loan)
+}
+
+// handleGetLoanByID - нахождение заявки по id.
+func (s *Server) handleGetLoanByID(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.loansSvc.ByID(request.Context(), id)
+	if err != nil {
+		respondLoansError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
+
+// handleGetCustomerLoans - заявки покупателя.
+func (s *Server) handleGetCustomerLoans(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.loansSvc.ByCustomer(request.Context(), id)
+	if err != nil {
+		respondLoansError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleLoanSchedule - график погашения кредита.
+func (s *Server) handleLoanSchedule(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.loansSvc.Schedule(request.Context(), id)
+	if err != nil {
+		respondLoansError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
+
+// handleSubmitLoan - отправляет заявку на рассмотрение.
+func (s *Server) handleSubmitLoan(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.loansSvc.Submit(request.Context(), id)
+	if err != nil {
+		respondLoansError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
+
+// handleApproveLoan - одобряет заявку.
+func (s *Server) handleApproveLoan(writer http.ResponseWriter, request *http.Request) {
+	s.handleLoanDecision(writer, request, true)
+}
+
+// handleRejectLoan - отклоняет заявку.
+func (s *Server) handleRejectLoan(writer http.ResponseWriter, request *http.Request) {
+	s.handleLoanDecision(writer, request, false)
+}
+
+func (s *Server) handleLoanDecision(writer http.ResponseWriter, request *http.Request, approve bool) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	manager, ok := requestManager(writer, request)
+	if !ok {
+		return
+	}
+	var decision LoanDecision
+	if request.ContentLength != 0 {
+		err = binding.Decode(request, &decision)
+		if err != nil {
+			respondDecodeError(writer, request, err)
+			return
+		}
+	}
+
+	var item *loans.Loan
+	if approve {
+		item, err = s.loansSvc.Approve(request.Context(), id, manager.ID)
+	} else {
+		item, err = s.loansSvc.Reject(request.Context(), id, manager.ID, decision.Reason)
+	}
+	if err != nil {
+		respondLoansError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
+
+// handleDisburseLoan - выдаёт одобренный кредит.
+func (s *Server) handleDisburseLoan(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if _, ok := requestManager(writer, request); !ok {
+		return
+	}
+
+	item, err := s.loansSvc.Disburse(request.Context(), id)
+	if err != nil {
+		respondLoansError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
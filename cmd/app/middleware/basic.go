@@ -1,25 +1,51 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"errors"
 	"net/http"
+
+	"github.com/az1zcheckit/crud/pkg/accesslog"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/security"
 )
 
-// Basic - middleware, basic это функция для авторизации.
-func Basic(auth func(login, pass string) bool) func(handler http.Handler) http.Handler {
+// Basic - middleware для маршрутов только для менеджеров. Менеджеры, уже опознанные
+// по клиентскому сертификату, пароль не вводят; остальные передают логин и пароль
+// в Basic-аутентификации, auth проверяет их и возвращает id менеджера.
+// Опознанный менеджер доступен обработчику через ManagerFromContext.
+func Basic(auth func(ctx context.Context, login, pass string) (int64, error)) func(handler http.Handler) http.Handler {
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if _, ok := ManagerFromContext(request.Context()); ok {
+				handler.ServeHTTP(writer, request)
+				return
+			}
 			username, password, ok := request.BasicAuth()
 			if !ok {
-				log.Print("Can't parse username and password")
-				http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				logger.Ctx(request.Context()).Warn("can't parse basic auth credentials")
+				unauthorized(writer)
 				return
 			}
-			if !auth(username, password) {
-				http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			id, err := auth(request.Context(), username, password)
+			if errors.Is(err, security.ErrNoSuchUser) || errors.Is(err, security.ErrInvalidPassword) {
+				logger.Ctx(request.Context()).Warnw("invalid manager credentials", "login", username, "error", err)
+				unauthorized(writer)
 				return
 			}
-			handler.ServeHTTP(writer, request)
+			if err != nil {
+				logger.Ctx(request.Context()).Error(err)
+				http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			accesslog.SetPrincipal(request.Context(), "manager:"+username)
+			ctx := context.WithValue(request.Context(), managerKey{}, &Manager{ID: id, Login: username})
+			handler.ServeHTTP(writer, request.WithContext(ctx))
 		})
 	}
 }
+
+func unauthorized(writer http.ResponseWriter) {
+	writer.Header().Set("WWW-Authenticate", `Basic realm="managers", charset="UTF-8"`)
+	http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/az1zcheckit/crud/pkg/accesslog"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/security"
+)
+
+type customerKey struct{}
+
+// CustomerFromContext возвращает id покупателя, опознанного Bearer.
+func CustomerFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(customerKey{}).(int64)
+	return id, ok
+}
+
+// BearerToken возвращает токен из заголовка Authorization: Bearer <token> или пустую строку.
+func BearerToken(request *http.Request) string {
+	header := request.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+// Bearer - middleware для маршрутов покупателя: токен из заголовка Authorization: Bearer
+// проверяет auth и возвращает id покупателя. Без токена, с неизвестным или истёкшим
+// токеном отвечает 401. Опознанный покупатель доступен обработчику через CustomerFromContext.
+func Bearer(auth func(ctx context.Context, token string) (int64, error)) func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			token := BearerToken(request)
+			if token == "" {
+				logger.Ctx(request.Context()).Warn("no bearer token")
+				bearerUnauthorized(writer)
+				return
+			}
+			id, err := auth(request.Context(), token)
+			if errors.Is(err, security.ErrNoSuchUser) || errors.Is(err, security.ErrExpiredToken) {
+				logger.Ctx(request.Context()).Warnw("invalid customer token", "error", err)
+				bearerUnauthorized(writer)
+				return
+			}
+			if err != nil {
+				logger.Ctx(request.Context()).Error(err)
+				http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			accesslog.SetPrincipal(request.Context(), "customer:"+strconv.FormatInt(id, 10))
+			ctx := context.WithValue(request.Context(), customerKey{}, id)
+			handler.ServeHTTP(writer, request.WithContext(ctx))
+		})
+	}
+}
+
+func bearerUnauthorized(writer http.ResponseWriter) {
+	writer.Header().Set("WWW-Authenticate", `Bearer realm="customers"`)
+	http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/az1zcheckit/crud/pkg/accesslog"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/security"
+)
+
+type managerKey struct{}
+
+// Manager - менеджер, прошедший аутентификацию по клиентскому сертификату или паролю.
+type Manager struct {
+	ID    int64
+	Login string
+}
+
+// ManagerFromContext возвращает менеджера, определённого ClientCert или Basic.
+func ManagerFromContext(ctx context.Context) (*Manager, bool) {
+	manager, ok := ctx.Value(managerKey{}).(*Manager)
+	return manager, ok
+}
+
+// ClientCert - middleware аутентификации менеджеров по клиентскому сертификату (mTLS).
+// Common Name проверенного сертификата - логин менеджера; lookup возвращает его id.
+// Сертификат, подписанный доверенным центром, но не принадлежащий активному менеджеру,
+// отклоняется с 403. Запросы без сертификата пропускаются как есть: маршруты только
+// для менеджеров дополнительно закрываются Basic.
+func ClientCert(lookup func(ctx context.Context, login string) (int64, error)) func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 {
+				handler.ServeHTTP(writer, request)
+				return
+			}
+			login := request.TLS.VerifiedChains[0][0].Subject.CommonName
+			id, err := lookup(request.Context(), login)
+			if errors.Is(err, security.ErrNoSuchUser) {
+				logger.Ctx(request.Context()).Warnw("client certificate is not mapped to a manager", "login", login)
+				http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				logger.Ctx(request.Context()).Error(err)
+				http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			accesslog.SetPrincipal(request.Context(), "manager:"+login)
+			ctx := context.WithValue(request.Context(), managerKey{}, &Manager{ID: id, Login: login})
+			handler.ServeHTTP(writer, request.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions - настройки CORS.
+type CORSOptions struct {
+	// AllowedOrigins - разрешённые источники (https://example.com); "*" - любой.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// AllowCredentials разрешает запросы с cookie; не сочетается с "*".
+	AllowCredentials bool
+	// MaxAge - сколько браузер может кэшировать ответ на preflight.
+	MaxAge time.Duration
+}
+
+// CORS - middleware, которое добавляет заголовки Access-Control-* для разрешённых источников
+// и само отвечает на preflight-запросы (OPTIONS с Access-Control-Request-Method).
+// Если источников нет, middleware ничего не делает.
+func CORS(opts CORSOptions) func(handler http.Handler) http.Handler {
+	origins := make(map[string]bool, len(opts.AllowedOrigins))
+	for _, origin := range opts.AllowedOrigins {
+		origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	exposed := strings.Join(opts.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(handler http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return handler
+		}
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			origin := request.Header.Get("Origin")
+			header := writer.Header()
+			header.Add("Vary", "Origin")
+			allowed := origin != "" && (origins[origin] || origins["*"])
+
+			preflight := request.Method == http.MethodOptions && request.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+			}
+			if !allowed {
+				if preflight {
+					writer.WriteHeader(http.StatusForbidden)
+					return
+				}
+				handler.ServeHTTP(writer, request)
+				return
+			}
+
+			if origins["*"] && !opts.AllowCredentials {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposed != "" {
+					header.Set("Access-Control-Expose-Headers", exposed)
+				}
+				handler.ServeHTTP(writer, request)
+				return
+			}
+
+			header.Set("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				header.Set("Access-Control-Allow-Headers", headers)
+			}
+			if opts.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			writer.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
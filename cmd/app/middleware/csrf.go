@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+const (
+	// CSRFHeader - заголовок с CSRF-токеном для запросов из JavaScript.
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFField - поле формы с CSRF-токеном.
+	CSRFField = "csrf_token"
+)
+
+// CSRFOptions - настройки защиты от CSRF.
+type CSRFOptions struct {
+	// Secret - ключ подписи токенов; если пустой, генерируется при запуске
+	// (тогда токены не переживают перезапуск и не подходят другим экземплярам).
+	Secret []byte
+	// Cookies - cookie аутентификации. Проверка нужна только запросам с ними:
+	// клиенты с заголовком Authorization браузер сам не подставляет.
+	Cookies []string
+}
+
+// CSRF проверяет токены для форм, отправляемых с cookie аутентификации.
+// Токен - HMAC от значения cookie сессии, поэтому хранить его не нужно,
+// а с новой сессией меняется и токен.
+type CSRF struct {
+	secret  []byte
+	cookies []string
+}
+
+// NewCSRF создаёт защиту от CSRF.
+func NewCSRF(opts CSRFOptions) (*CSRF, error) {
+	secret := opts.Secret
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &CSRF{secret: secret, cookies: opts.Cookies}, nil
+}
+
+// Token возвращает CSRF-токен для сессии запроса или пустую строку, если сессии нет.
+// Токен вставляется в формы (поле csrf_token) или передаётся в заголовке X-CSRF-Token.
+func (c *CSRF) Token(request *http.Request) string {
+	cookie := c.session(request)
+	if cookie == nil {
+		return ""
+	}
+	return c.sign(cookie)
+}
+
+// Middleware отклоняет изменяющие запросы с cookie аутентификации без верного токена.
+func (c *CSRF) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			handler.ServeHTTP(writer, request)
+			return
+		}
+		cookie := c.session(request)
+		if cookie == nil {
+			handler.ServeHTTP(writer, request)
+			return
+		}
+
+		token := request.Header.Get(CSRFHeader)
+		if token == "" {
+			token = request.PostFormValue(CSRFField)
+		}
+		if !hmac.Equal([]byte(token), []byte(c.sign(cookie))) {
+			logger.Ctx(request.Context()).Warnw("csrf token mismatch", "cookie", cookie.Name)
+			http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+func (c *CSRF) session(request *http.Request) *http.Cookie {
+	for _, name := range c.cookies {
+		if cookie, err := request.Cookie(name); err == nil && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func (c *CSRF) sign(cookie *http.Cookie) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(cookie.Name + "=" + cookie.Value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
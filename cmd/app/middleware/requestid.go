@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader - заголовок с id запроса.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength - id длиннее этого считается недопустимым и заменяется новым.
+const maxRequestIDLength = 128
+
+// RequestID - middleware, которое берёт id запроса из X-Request-ID или создаёт новый,
+// возвращает его в ответе и кладёт в контекст вместе с логгером log,
+// чтобы каждая запись обработчиков и сервисов содержала request_id.
+func RequestID(log *zap.Logger) func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			id := request.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			writer.Header().Set(RequestIDHeader, id)
+			ctx := logger.WithRequestID(request.Context(), log, id)
+			handler.ServeHTTP(writer, request.WithContext(ctx))
+		})
+	}
+}
+
+// validRequestID допускает только печатные ASCII-символы, чтобы id нельзя было использовать для подделки записей журнала.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buffer := make([]byte, 16)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buffer)
+}
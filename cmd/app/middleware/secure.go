@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SecureHeadersOptions - настройки заголовков безопасности.
+type SecureHeadersOptions struct {
+	// ContentSecurityPolicy - значение Content-Security-Policy; пустая строка - не отправлять.
+	ContentSecurityPolicy string
+	// FrameOptions - значение X-Frame-Options (DENY, SAMEORIGIN); пустая строка - не отправлять.
+	FrameOptions string
+	// HSTSMaxAge - max-age для Strict-Transport-Security; 0 - не отправлять.
+	// Заголовок отправляется только в ответ на запросы по HTTPS.
+	HSTSMaxAge time.Duration
+}
+
+// SecureHeaders - middleware, которое добавляет стандартные заголовки безопасности.
+func SecureHeaders(opts SecureHeadersOptions) func(handler http.Handler) http.Handler {
+	hsts := "max-age=" + strconv.Itoa(int(opts.HSTSMaxAge.Seconds())) + "; includeSubDomains"
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			header := writer.Header()
+			header.Set("X-Content-Type-Options", "nosniff")
+			header.Set("Referrer-Policy", "no-referrer")
+			if opts.FrameOptions != "" {
+				header.Set("X-Frame-Options", opts.FrameOptions)
+			}
+			if opts.ContentSecurityPolicy != "" {
+				header.Set("Content-Security-Policy", opts.ContentSecurityPolicy)
+			}
+			if opts.HSTSMaxAge > 0 && request.TLS != nil {
+				header.Set("Strict-Transport-Security", hsts)
+			}
+			handler.ServeHTTP(writer, request)
+		})
+	}
+}
@@ -0,0 +1,718 @@
+package app
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/az1zcheckit/crud/pkg/cards"
+	"github.com/az1zcheckit/crud/pkg/customers"
+	"github.com/az1zcheckit/crud/pkg/health"
+	"github.com/az1zcheckit/crud/pkg/kyc"
+	"github.com/az1zcheckit/crud/pkg/loans"
+	"github.com/az1zcheckit/crud/pkg/openapi"
+	"github.com/az1zcheckit/crud/pkg/products"
+	"github.com/az1zcheckit/crud/pkg/rates"
+	"github.com/az1zcheckit/crud/pkg/sales"
+	"github.com/az1zcheckit/crud/pkg/security"
+	"github.com/az1zcheckit/crud/pkg/version"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+//go:embed docs
+var docsFiles embed.FS
+
+// handleOpenAPI - спецификация OpenAPI 3.
+func (s *Server) handleOpenAPI(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	_, err := writer.Write(s.openAPI)
+	if err != nil {
+		zap.S().Error(err)
+	}
+}
+
+// docsHandler отдаёт страницу документации API и её статические файлы.
+func docsHandler() http.Handler {
+	files, err := fs.Sub(docsFiles, "docs")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/docs", http.FileServer(http.FS(files)))
+}
+
+// buildOpenAPI собирает спецификацию по зарегистрированным маршрутам:
+// для каждого маршрута берётся описание из operations, для устаревших путей -
+// описание маршрута /api/v1, который их заменяет. Маршруты без описания
+// в спецификацию не попадают (это проверяет тест), как и описания без маршрута.
+func (s *Server) buildOpenAPI() (*openapi.Document, error) {
+	doc := openapi.New(openapi.Info{
+		Title:       "crud",
+		Description: "Покупатели, карты, продажи, продукты, кредиты, курсы валют и проверка покупателей.",
+		Version:     version.Get().Version,
+	})
+	doc.Tags = apiTags
+	doc.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		"manager":  {Type: "http", Scheme: "basic", Description: "Логин и пароль менеджера; с клиентским сертификатом менеджера не нужны"},
+		"customer": {Type: "http", Scheme: "bearer", Description: "Токен покупателя из POST /api/v1/customers/token"},
+	}
+	described := operations(doc)
+
+	err := s.mux.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if op, ok := described[method+" "+template]; ok {
+				doc.Add(method, template, op)
+				continue
+			}
+			// прежний путь описывается так же, как маршрут, который его заменяет
+			successor, ok := s.legacy[method+" "+template]
+			if !ok {
+				continue
+			}
+			if op, ok := described[successor]; ok {
+				legacy := *op
+				legacy.Deprecated = true
+				legacy.Description = strings.TrimSpace("Устарел, используйте " + successor + ". " + op.Description)
+				doc.Add(method, template, &legacy)
+			}
+		}
+		return nil
+	})
+	return doc, err
+}
+
+var apiTags = []*openapi.Tag{
+	{Name: "customers", Description: "Покупатели"},
+	{Name: "auth", Description: "Токены покупателей"},
+	{Name: "cards", Description: "Карты и переводы"},
+	{Name: "sales", Description: "Продажи и отчёты по менеджерам"},
+	{Name: "products", Description: "Каталог продуктов"},
+	{Name: "loans", Description: "Заявки на кредит"},
+	{Name: "rates", Description: "Курсы валют"},
+	{Name: "kyc", Description: "Проверка покупателей"},
+	{Name: "service", Description: "Служебные маршруты"},
+	{Name: "admin", Description: "Веб-интерфейс менеджеров (HTML, сессия в cookie session)"},
+	{Name: "portal", Description: "Кабинет покупателя (HTML, токен в cookie customer_session)"},
+}
+
+// operations описывает каждый маршрут из Init; ключ - "МЕТОД шаблон".
+// Прежние пути без версии отдельно не описываются.
+func operations(doc *openapi.Document) map[string]*openapi.Operation {
+	fail := func(description string) *openapi.Response {
+		return doc.JSON(description, &ResponceFail{})
+	}
+	text := func(description string) *openapi.Response {
+		return &openapi.Response{
+			Description: description,
+			Content:     map[string]*openapi.MediaType{"text/plain": {Schema: openapi.String}},
+		}
+	}
+	period := []*openapi.Parameter{
+		openapi.Query("from", openapi.Date, "начало периода, по умолчанию - начало месяца"),
+		openapi.Query("to", openapi.Date, "конец периода включительно"),
+	}
+	csv := openapi.Query("format", &openapi.Schema{Type: "string", Enum: []string{"csv"}}, "csv - выгрузить отчёт в CSV")
+	customersFilter := []*openapi.Parameter{
+		openapi.Query("q", openapi.String, "подстрока имени или телефона"),
+		openapi.Query("active", openapi.Boolean, "только активные или только заблокированные"),
+		openapi.Query("created_from", openapi.Date, "созданы не раньше"),
+		openapi.Query("created_to", openapi.Date, "созданы не позже, включительно"),
+	}
+	bulk := func(summary string) *openapi.Operation {
+		body := doc.JSONBody(&BulkRequest{})
+		body.Required = false
+		return &openapi.Operation{
+			Tags: []string{"customers"}, Summary: summary,
+			Description: "Покупатели задаются списком ids в теле или фильтром в строке запроса (не больше " +
+				strconv.Itoa(customers.MaxBulk) + "). Операция выполняется в одной транзакции, изменения записываются в журнал.",
+			Parameters:  customersFilter,
+			RequestBody: body,
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Итог по каждому покупателю", &customers.BulkReport{}),
+				"400": fail("Нет покупателей, пустой фильтр или слишком много покупателей"),
+			},
+		}
+	}
+	report := func(description string, v interface{}) *openapi.Response {
+		response := doc.JSON(description, v)
+		response.Content["text/csv"] = &openapi.MediaType{Schema: openapi.File}
+		return response
+	}
+	page := func(tag string, summary string) *openapi.Operation {
+		return &openapi.Operation{
+			Tags: []string{tag}, Summary: summary,
+			Responses: map[string]*openapi.Response{
+				"200": openapi.Content("Страница", "text/html"),
+				"303": openapi.Empty("Нет сессии - переход на страницу входа"),
+			},
+		}
+	}
+	action := func(tag string, summary string, fields map[string]*openapi.Schema) *openapi.Operation {
+		fields["csrf_token"] = openapi.String
+		return &openapi.Operation{
+			Tags: []string{tag}, Summary: summary,
+			RequestBody: &openapi.RequestBody{
+				Required: true,
+				Content: map[string]*openapi.MediaType{
+					"application/x-www-form-urlencoded": {Schema: &openapi.Schema{Type: "object", Properties: fields}},
+				},
+			},
+			Responses: map[string]*openapi.Response{
+				"303": openapi.Empty("Переход на следующую страницу с сообщением об итоге"),
+				"403": text("Неверный CSRF-токен"),
+			},
+		}
+	}
+	date := openapi.Query("date", openapi.Date, "дата, по умолчанию - сегодня")
+	legacyID := &openapi.Parameter{Name: "id", In: "query", Required: true, Schema: openapi.Integer}
+	legacyForm := openapi.Form(map[string]*openapi.Schema{"id": openapi.Integer})
+
+	described := map[string]*openapi.Operation{
+		"GET /api/v1/customers": {
+			Tags: []string{"customers"}, Summary: "Все покупатели",
+			Parameters: customersFilter,
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Покупатели", []*customers.Customer{}),
+				"400": fail("Неверный фильтр"),
+			},
+		},
+		"GET /api/v1/customers/export": {
+			Tags: []string{"customers"}, Summary: "Выгрузка покупателей",
+			Description: "Файл отдаётся по мере чтения из базы; фильтры те же, что у списка.",
+			Parameters: append([]*openapi.Parameter{
+				openapi.Query("format", &openapi.Schema{Type: "string", Enum: []string{customers.FormatCSV, customers.FormatJSONL, customers.FormatXLSX}}, "по умолчанию csv"),
+			}, customersFilter...),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.Content("Файл выгрузки", customers.ExportFormats[customers.FormatCSV], customers.ExportFormats[customers.FormatJSONL], customers.ExportFormats[customers.FormatXLSX]),
+				"400": fail("Неверный формат или фильтр"),
+			},
+		},
+		"GET /api/v1/customers/active": {
+			Tags: []string{"customers"}, Summary: "Активные покупатели",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Покупатели", []*customers.Customer{})},
+		},
+		"GET /api/v1/customers/{id}": {
+			Tags: []string{"customers"}, Summary: "Покупатель по id",
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Покупатель", &customers.Customer{}),
+				"400": text("Неверный id"),
+			},
+		},
+		"POST /api/v1/customers": {
+			Tags: []string{"customers"}, Summary: "Создать или обновить покупателя",
+			Description: "Без id покупатель создаётся (при совпадении телефона обновляется), с id - обновляется.",
+			RequestBody: doc.JSONBody(&customers.Customer{}),
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Сохранённый покупатель", &customers.Customer{}),
+				"400": text("Неверное тело запроса"),
+			},
+		},
+		"POST /api/v1/customers/import": {
+			Tags: []string{"customers"}, Summary: "Импорт покупателей из CSV",
+			Description: "Колонки name, phone (обязательные), active, created (YYYY-MM-DD или RFC 3339). " +
+				"Покупатели с существующим телефоном обновляются. Если хотя бы одна строка ошибочна, ничего не записывается.",
+			Parameters: []*openapi.Parameter{openapi.Query("dry_run", openapi.Boolean, "Только проверить файл")},
+			RequestBody: &openapi.RequestBody{
+				Required: true,
+				Content: map[string]*openapi.MediaType{
+					mimeCSV:               {Schema: openapi.File},
+					"multipart/form-data": {Schema: &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{"file": openapi.File}}},
+				},
+			},
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Отчёт об импорте", &customers.ImportReport{}),
+				"400": fail("Файл нельзя разобрать"),
+				"415": text("Неподдерживаемый тип содержимого"),
+				"422": doc.JSON("Отчёт с ошибочными строками", &customers.ImportReport{}),
+			},
+		},
+		"POST /api/v1/customers/bulk/block":   bulk("Заблокировать покупателей"),
+		"POST /api/v1/customers/bulk/unblock": bulk("Разблокировать покупателей"),
+		"POST /api/v1/customers/bulk/delete":  bulk("Удалить покупателей"),
+		"DELETE /api/v1/customers/{id}": {
+			Tags: []string{"customers"}, Summary: "Удалить покупателя",
+			Description: "Вместе с покупателем удаляются его токены; удаление записывается в журнал customers_audit.",
+			Responses: map[string]*openapi.Response{
+				"200": openapi.Empty("Удалён"),
+				"404": fail("Покупатель не найден"),
+				"409": fail("На покупателя ссылаются карты, кредиты или продажи"),
+			},
+		},
+		"POST /api/v1/customers/{id}/block": {
+			Tags: []string{"customers"}, Summary: "Заблокировать покупателя",
+			Description: "Токены покупателя удаляются; блокировка записывается в журнал customers_audit.",
+			Responses: map[string]*openapi.Response{
+				"200": openapi.Empty("Заблокирован"),
+				"404": fail("Покупатель не найден"),
+			},
+		},
+		"DELETE /api/v1/customers/{id}/block": {
+			Tags: []string{"customers"}, Summary: "Разблокировать покупателя",
+			Responses: map[string]*openapi.Response{
+				"200": openapi.Empty("Разблокирован"),
+				"404": fail("Покупатель не найден"),
+			},
+		},
+
+		"POST /api/v1/customers/register": {
+			Tags: []string{"auth"}, Summary: "Регистрация покупателя с паролем",
+			RequestBody: doc.JSONBody(&customers.Customer{}),
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Зарегистрированный покупатель", &customers.Customer{}),
+				"400": text("Неверное тело запроса"),
+				"429": text("Слишком много запросов"),
+			},
+		},
+		"POST /api/v1/customers/token": {
+			Tags: []string{"auth"}, Summary: "Получить токен покупателя",
+			Description: "login - телефон покупателя. Токен выдаётся только активным покупателям, прошедшим проверку.",
+			RequestBody: doc.JSONBody(&security.Auth{}),
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Токен", &Token{}),
+				"403": fail("Покупатель заблокирован (blocked) или не прошёл проверку (not verified)"),
+				"429": text("Слишком много запросов"),
+				"500": text("Неверный логин или пароль"),
+			},
+		},
+		"POST /api/v1/customers/token/validate": {
+			Tags: []string{"auth"}, Summary: "Проверить токен покупателя",
+			RequestBody: doc.JSONBody(&Token{}),
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Токен действителен", &ResponceOk{}),
+				"400": fail("Срок действия токена истёк"),
+				"404": fail("Токен не найден"),
+			},
+		},
+
+		"GET /api/v1/customers/{id}/cards": {
+			Tags: []string{"cards"}, Summary: "Карты покупателя",
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Карты", []*cards.Card{}),
+				"404": fail("Покупатель не найден"),
+			},
+		},
+		"POST /api/v1/customers/{id}/cards": {
+			Tags: []string{"cards"}, Summary: "Выпустить карту",
+			RequestBody: doc.JSONBody(&CardIssue{}),
+			Responses: map[string]*openapi.Response{
+				"201": doc.JSON("Выпущенная карта; полный номер возвращается только здесь", &cards.Card{}),
+				"400": fail("Покупатель заблокирован или неизвестная валюта"),
+				"403": fail("Покупатель не прошёл проверку"),
+				"404": fail("Покупатель не найден"),
+			},
+		},
+		"POST /api/v1/cards/transfer": {
+			Tags: []string{"cards"}, Summary: "Перевод с карты на карту",
+			RequestBody: doc.JSONBody(&CardTransfer{}),
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Проведённый перевод", &cards.Transaction{}),
+				"400": fail("Карта заблокирована, недостаточно средств или превышен лимит"),
+				"404": fail("Карта не найдена"),
+			},
+		},
+		"GET /api/v1/cards/{id}": {
+			Tags: []string{"cards"}, Summary: "Карта по id",
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Карта", &cards.Card{}),
+				"404": fail("Карта не найдена"),
+			},
+		},
+		"PUT /api/v1/cards/{id}/limit": {
+			Tags: []string{"cards"}, Summary: "Изменить дневной лимит",
+			RequestBody: doc.JSONBody(&CardLimit{}),
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Карта", &cards.Card{}),
+				"400": fail("Неверный лимит"),
+				"404": fail("Карта не найдена"),
+			},
+		},
+		"POST /api/v1/cards/{id}/block": {
+			Tags: []string{"cards"}, Summary: "Заблокировать карту",
+			Responses: map[string]*openapi.Response{"200": openapi.Empty("Заблокирована"), "404": fail("Карта не найдена")},
+		},
+		"DELETE /api/v1/cards/{id}/block": {
+			Tags: []string{"cards"}, Summary: "Разблокировать карту",
+			Responses: map[string]*openapi.Response{"200": openapi.Empty("Разблокирована"), "404": fail("Карта не найдена")},
+		},
+
+		"POST /api/v1/sales": {
+			Tags: []string{"sales"}, Summary: "Записать продажу",
+			Description: "Продажа записывается на менеджера, прошедшего аутентификацию; managerId в теле не учитывается.",
+			RequestBody: doc.JSONBody(&sales.Sale{}),
+			Responses: map[string]*openapi.Response{
+				"201": doc.JSON("Продажа", &sales.Sale{}),
+				"400": fail("Неверная продажа"),
+				"404": fail("Покупатель или продукт не найден"),
+			},
+		},
+		"GET /api/v1/managers/{id}/sales": {
+			Tags: []string{"sales"}, Summary: "Продажи менеджера за период",
+			Parameters: period,
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Продажи", []*sales.Sale{}),
+				"404": fail("Менеджер не найден"),
+			},
+		},
+		"GET /api/v1/sales/reports/managers": {
+			Tags: []string{"sales"}, Summary: "Выполнение плана менеджерами",
+			Parameters: append([]*openapi.Parameter{openapi.Query("department", openapi.String, "отдел"), csv}, period...),
+			Responses:  map[string]*openapi.Response{"200": report("Отчёт", []*sales.ManagerReport{})},
+		},
+		"GET /api/v1/sales/reports/departments": {
+			Tags: []string{"sales"}, Summary: "Выполнение плана отделами",
+			Parameters: append([]*openapi.Parameter{csv}, period...),
+			Responses:  map[string]*openapi.Response{"200": report("Отчёт", []*sales.DepartmentReport{})},
+		},
+		"GET /api/v1/sales/reports/top": {
+			Tags: []string{"sales"}, Summary: "Лучшие менеджеры за период",
+			Parameters: append([]*openapi.Parameter{openapi.Query("limit", openapi.Integer, "сколько менеджеров, по умолчанию 10"), csv}, period...),
+			Responses:  map[string]*openapi.Response{"200": report("Отчёт", []*sales.ManagerReport{})},
+		},
+
+		"GET /api/v1/products": {
+			Tags: []string{"products"}, Summary: "Каталог продуктов",
+			Parameters: []*openapi.Parameter{
+				openapi.Query("category", &openapi.Schema{Type: "string", Enum: []string{products.CategoryDeposit, products.CategoryLoan, products.CategoryCard}}, "категория"),
+				openapi.Query("available", openapi.Boolean, "true - только действующие"),
+			},
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Продукты", []*products.Product{})},
+		},
+		"POST /api/v1/products": {
+			Tags: []string{"products"}, Summary: "Создать или обновить продукт",
+			RequestBody: doc.JSONBody(&products.Product{}),
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Продукт", &products.Product{}),
+				"400": fail("Неверный продукт"),
+			},
+		},
+		"GET /api/v1/products/{id}": {
+			Tags: []string{"products"}, Summary: "Продукт по id",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Продукт", &products.Product{}), "404": fail("Продукт не найден")},
+		},
+		"DELETE /api/v1/products/{id}": {
+			Tags: []string{"products"}, Summary: "Удалить продукт",
+			Responses: map[string]*openapi.Response{"200": openapi.Empty("Удалён"), "404": fail("Продукт не найден")},
+		},
+		"GET /api/v1/products/{id}/prices": {
+			Tags: []string{"products"}, Summary: "История цен продукта",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Цены", []*products.Price{}), "404": fail("Продукт не найден")},
+		},
+		"GET /api/v1/products/{id}/attachments": {
+			Tags: []string{"products"}, Summary: "Вложения продукта",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Вложения", []*products.Attachment{}), "404": fail("Продукт не найден")},
+		},
+		"POST /api/v1/products/{id}/attachments": {
+			Tags: []string{"products"}, Summary: "Загрузить вложение",
+			RequestBody: openapi.Form(map[string]*openapi.Schema{"file": openapi.File}),
+			Responses: map[string]*openapi.Response{
+				"201": doc.JSON("Вложение", &products.Attachment{}),
+				"400": fail("Недопустимый тип или размер файла"),
+				"404": fail("Продукт не найден"),
+			},
+		},
+		"GET /api/v1/products/{id}/attachments/{attachmentId}": {
+			Tags: []string{"products"}, Summary: "Скачать вложение",
+			Responses: map[string]*openapi.Response{"200": openapi.Content("Файл", "application/octet-stream"), "404": fail("Вложение не найдено")},
+		},
+		"DELETE /api/v1/products/{id}/attachments/{attachmentId}": {
+			Tags: []string{"products"}, Summary: "Удалить вложение",
+			Responses: map[string]*openapi.Response{"200": openapi.Empty("Удалено"), "404": fail("Вложение не найдено")},
+		},
+		"GET /api/v1/customers/{id}/products": {
+			Tags: []string{"products"}, Summary: "Продукты покупателя",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Продукты", []*products.Product{})},
+		},
+
+		"POST /api/v1/loans": {
+			Tags: []string{"loans"}, Summary: "Создать или изменить черновик заявки",
+			RequestBody: doc.JSONBody(&loans.Loan{}),
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Заявка", &loans.Loan{}),
+				"400": fail("Неверные условия или покупатель заблокирован"),
+				"404": fail("Покупатель не найден"),
+			},
+		},
+		"GET /api/v1/loans/{id}": {
+			Tags: []string{"loans"}, Summary: "Заявка по id",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Заявка", &loans.Loan{}), "404": fail("Заявка не найдена")},
+		},
+		"GET /api/v1/loans/{id}/schedule": {
+			Tags: []string{"loans"}, Summary: "График платежей",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("График", &loans.Schedule{}), "404": fail("Заявка не найдена")},
+		},
+		"POST /api/v1/loans/{id}/submit": {
+			Tags: []string{"loans"}, Summary: "Отправить заявку на рассмотрение",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Заявка", &loans.Loan{}), "409": fail("Недопустимо в текущем статусе")},
+		},
+		"POST /api/v1/loans/{id}/approve": {
+			Tags: []string{"loans"}, Summary: "Одобрить заявку",
+			Description: "Заявку одобряет менеджер, прошедший аутентификацию.",
+			Responses:   map[string]*openapi.Response{"200": doc.JSON("Заявка", &loans.Loan{}), "409": fail("Недопустимо в текущем статусе")},
+		},
+		"POST /api/v1/loans/{id}/reject": {
+			Tags: []string{"loans"}, Summary: "Отклонить заявку",
+			Description: "Заявку отклоняет менеджер, прошедший аутентификацию.",
+			RequestBody: doc.JSONBody(&LoanDecision{}),
+			Responses:   map[string]*openapi.Response{"200": doc.JSON("Заявка", &loans.Loan{}), "409": fail("Недопустимо в текущем статусе")},
+		},
+		"POST /api/v1/loans/{id}/disburse": {
+			Tags: []string{"loans"}, Summary: "Выдать одобренный кредит",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Заявка", &loans.Loan{}), "409": fail("Недопустимо в текущем статусе")},
+		},
+		"GET /api/v1/customers/{id}/loans": {
+			Tags: []string{"loans"}, Summary: "Заявки покупателя",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Заявки", []*loans.Loan{})},
+		},
+
+		"GET /api/v1/rates": {
+			Tags: []string{"rates"}, Summary: "Курсы валют на дату",
+			Parameters: []*openapi.Parameter{date},
+			Responses:  map[string]*openapi.Response{"200": doc.JSON("Курсы", []*rates.Rate{})},
+		},
+		"POST /api/v1/rates": {
+			Tags: []string{"rates"}, Summary: "Сохранить курс или загрузить курсы из CSV",
+			RequestBody: &openapi.RequestBody{
+				Required: true,
+				Content: map[string]*openapi.MediaType{
+					"application/json": {Schema: doc.Schema(&rates.Rate{})},
+					"text/csv":         {Schema: openapi.File},
+				},
+			},
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Сохранённый курс или {\"loaded\": количество} для CSV", &rates.Rate{}),
+				"400": fail("Неверный курс"),
+			},
+		},
+		"GET /api/v1/rates/convert": {
+			Tags: []string{"rates"}, Summary: "Пересчитать сумму в другую валюту",
+			Parameters: []*openapi.Parameter{
+				openapi.Query("amount", openapi.String, "сумма, например 100.50"),
+				openapi.Query("from", openapi.String, "код валюты суммы"),
+				openapi.Query("to", openapi.String, "код валюты результата"),
+				date,
+			},
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Результат", &Conversion{}), "404": fail("Курс не найден")},
+		},
+
+		"GET /api/v1/customers/{id}/profile": {
+			Tags: []string{"kyc"}, Summary: "Профиль покупателя",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Профиль", &kyc.Profile{}), "404": fail("Профиль не найден")},
+		},
+		"PUT /api/v1/customers/{id}/profile": {
+			Tags: []string{"kyc"}, Summary: "Заполнить профиль",
+			RequestBody: doc.JSONBody(&kyc.Profile{}),
+			Responses:   map[string]*openapi.Response{"200": doc.JSON("Профиль", &kyc.Profile{}), "400": fail("Неверный профиль")},
+		},
+		"POST /api/v1/customers/{id}/kyc/verify": {
+			Tags: []string{"kyc"}, Summary: "Подтвердить профиль",
+			Description: "Профиль подтверждает менеджер, прошедший аутентификацию.",
+			Responses:   map[string]*openapi.Response{"200": doc.JSON("Профиль", &kyc.Profile{}), "409": fail("Недопустимо в текущем статусе")},
+		},
+		"POST /api/v1/customers/{id}/kyc/reject": {
+			Tags: []string{"kyc"}, Summary: "Отклонить профиль",
+			Description: "Профиль отклоняет менеджер, прошедший аутентификацию.",
+			RequestBody: doc.JSONBody(&KYCDecision{}),
+			Responses:   map[string]*openapi.Response{"200": doc.JSON("Профиль", &kyc.Profile{}), "409": fail("Недопустимо в текущем статусе")},
+		},
+		"GET /api/v1/customers/{id}/documents": {
+			Tags: []string{"kyc"}, Summary: "Документы покупателя",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Документы", []*kyc.Document{})},
+		},
+		"POST /api/v1/customers/{id}/documents": {
+			Tags: []string{"kyc"}, Summary: "Загрузить документ",
+			RequestBody: openapi.Form(map[string]*openapi.Schema{
+				"file": openapi.File,
+				"kind": {Type: "string", Enum: []string{kyc.DocumentPassport, kyc.DocumentOther}},
+			}),
+			Responses: map[string]*openapi.Response{
+				"201": doc.JSON("Документ", &kyc.Document{}),
+				"400": fail("Недопустимый тип или размер файла"),
+			},
+		},
+		"GET /api/v1/customers/{id}/documents/{documentId}": {
+			Tags: []string{"kyc"}, Summary: "Скачать документ",
+			Responses: map[string]*openapi.Response{"200": openapi.Content("Файл", "application/octet-stream"), "404": fail("Документ не найден")},
+		},
+		"GET /api/v1/profiles/pending": {
+			Tags: []string{"kyc"}, Summary: "Профили, ожидающие проверки",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Профили", []*kyc.Profile{})},
+		},
+
+		"GET /customers.getAll": {
+			Tags: []string{"customers"}, Summary: "Все покупатели", Deprecated: true,
+			Description: "Устарел, используйте GET /api/v1/customers.",
+			Responses:   map[string]*openapi.Response{"200": doc.JSON("Покупатели", []*customers.Customer{})},
+		},
+		"GET /customers.getAllActive": {
+			Tags: []string{"customers"}, Summary: "Активные покупатели", Deprecated: true,
+			Description: "Устарел, используйте GET /api/v1/customers/active.",
+			Responses:   map[string]*openapi.Response{"200": doc.JSON("Покупатели", []*customers.Customer{})},
+		},
+		"GET /customers.getById": {
+			Tags: []string{"customers"}, Summary: "Покупатель по id", Deprecated: true,
+			Description: "Устарел, используйте GET /api/v1/customers/{id}.",
+			Parameters:  []*openapi.Parameter{legacyID},
+			Responses:   map[string]*openapi.Response{"200": doc.JSON("Покупатель", &customers.Customer{}), "400": text("Неверный id")},
+		},
+		"POST /customers.save": {
+			Tags: []string{"customers"}, Summary: "Создать или обновить покупателя из формы", Deprecated: true,
+			Description: "Устарел, используйте POST /api/v1/customers.",
+			RequestBody: openapi.Form(map[string]*openapi.Schema{
+				"id":      openapi.Integer,
+				"name":    openapi.String,
+				"phone":   openapi.String,
+				"active":  openapi.String,
+				"created": openapi.Date,
+			}),
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Сохранённый покупатель", &customers.Customer{}), "400": text("Неверная форма")},
+		},
+		"POST /customers.removeById": {
+			Tags: []string{"customers"}, Summary: "Удалить покупателя", Deprecated: true,
+			Description: "Устарел, используйте DELETE /api/v1/customers/{id}.",
+			RequestBody: legacyForm,
+			Responses:   map[string]*openapi.Response{"200": openapi.Empty("Удалён")},
+		},
+		"POST /customers.blockById": {
+			Tags: []string{"customers"}, Summary: "Заблокировать покупателя", Deprecated: true,
+			Description: "Устарел, используйте POST /api/v1/customers/{id}/block.",
+			RequestBody: legacyForm,
+			Responses:   map[string]*openapi.Response{"200": openapi.Empty("Заблокирован")},
+		},
+		"POST /customers.unblockById": {
+			Tags: []string{"customers"}, Summary: "Разблокировать покупателя", Deprecated: true,
+			Description: "Устарел, используйте DELETE /api/v1/customers/{id}/block.",
+			RequestBody: legacyForm,
+			Responses:   map[string]*openapi.Response{"200": openapi.Empty("Разблокирован")},
+		},
+
+		"GET /healthz": {
+			Tags: []string{"service"}, Summary: "Процесс жив",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("OK", &Health{})},
+		},
+		"GET /readyz": {
+			Tags: []string{"service"}, Summary: "Готовность принимать трафик",
+			Responses: map[string]*openapi.Response{
+				"200": doc.JSON("Все проверки прошли", &health.Report{}),
+				"503": doc.JSON("Хотя бы одна проверка не прошла", &health.Report{}),
+			},
+		},
+		"GET /version": {
+			Tags: []string{"service"}, Summary: "Версия сборки",
+			Responses: map[string]*openapi.Response{"200": doc.JSON("Версия", &version.Info{})},
+		},
+		"GET /metrics": {
+			Tags: []string{"service"}, Summary: "Метрики Prometheus",
+			Responses: map[string]*openapi.Response{"200": text("Метрики в текстовом формате")},
+		},
+		"GET /openapi.json": {
+			Tags: []string{"service"}, Summary: "Эта спецификация",
+			Responses: map[string]*openapi.Response{"200": {Description: "Спецификация OpenAPI 3", Content: map[string]*openapi.MediaType{"application/json": {Schema: &openapi.Schema{Type: "object"}}}}},
+		},
+		"GET /docs/": {
+			Tags: []string{"service"}, Summary: "Страница документации API",
+			Responses: map[string]*openapi.Response{"200": openapi.Content("Страница и её файлы", "text/html")},
+		},
+
+		"GET /admin/":      page("admin", "Переход к списку покупателей"),
+		"GET /admin/login": page("admin", "Форма входа"),
+		"POST /admin/login": func() *openapi.Operation {
+			op := action("admin", "Вход менеджера", map[string]*openapi.Schema{"login": openapi.String, "password": openapi.String})
+			op.Description = "Открывает сессию на 12 часов и ставит cookie session."
+			op.Responses["401"] = openapi.Content("Неверный логин или пароль", "text/html")
+			return op
+		}(),
+		"POST /admin/logout": action("admin", "Выход", map[string]*openapi.Schema{}),
+		"GET /admin/customers": func() *openapi.Operation {
+			op := page("admin", "Список покупателей")
+			op.Parameters = []*openapi.Parameter{
+				openapi.Query("q", openapi.String, "часть имени или телефона"),
+				openapi.Query("page", openapi.Integer, "номер страницы, по 20 покупателей"),
+			}
+			return op
+		}(),
+		"GET /admin/customers/{id}": page("admin", "Карточка покупателя"),
+		"POST /admin/customers/{id}/{action}": func() *openapi.Operation {
+			op := action("admin", "Заблокировать, разблокировать или удалить покупателя", map[string]*openapi.Schema{
+				"confirm": &openapi.Schema{Type: "string", Description: "обязательно для delete"},
+			})
+			op.Parameters = []*openapi.Parameter{{
+				Name: "action", In: "path", Required: true,
+				Schema: &openapi.Schema{Type: "string", Enum: []string{"block", "unblock", "delete"}},
+			}}
+			return op
+		}(),
+		"GET /admin/static/": {
+			Tags: []string{"admin"}, Summary: "Стили веб-интерфейса",
+			Responses: map[string]*openapi.Response{"200": openapi.Content("Файл", "text/css")},
+		},
+
+		"GET /portal/":      page("portal", "Профиль покупателя"),
+		"GET /portal/login": page("portal", "Форма входа по телефону и паролю"),
+		"POST /portal/login": func() *openapi.Operation {
+			op := action("portal", "Вход покупателя", map[string]*openapi.Schema{"phone": openapi.String, "password": openapi.String})
+			op.Description = "Выдаёт токен, как POST /api/v1/customers/token, и кладёт его в HttpOnly cookie customer_session."
+			op.Responses["401"] = openapi.Content("Неверный телефон или пароль", "text/html")
+			op.Responses["403"] = openapi.Content("Профиль не прошёл проверку", "text/html")
+			return op
+		}(),
+		"POST /portal/logout": action("portal", "Выход с завершением сессии", map[string]*openapi.Schema{}),
+		"POST /portal/password": action("portal", "Смена пароля", map[string]*openapi.Schema{
+			"current":  openapi.String,
+			"password": &openapi.Schema{Type: "string", Description: "не короче 8 символов"},
+			"confirm":  openapi.String,
+		}),
+		"GET /portal/sessions":                page("portal", "Действующие сессии"),
+		"POST /portal/sessions/{id}/revoke":   action("portal", "Завершить сессию", map[string]*openapi.Schema{}),
+		"POST /portal/sessions/others/revoke": action("portal", "Завершить все сессии, кроме текущей", map[string]*openapi.Schema{}),
+		"GET /portal/static/": {
+			Tags: []string{"portal"}, Summary: "Стили кабинета",
+			Responses: map[string]*openapi.Response{"200": openapi.Content("Файл", "text/css")},
+		},
+	}
+	// маршруты, закрытые Server.managerOnly
+	for _, key := range []string{
+		"GET /api/v1/customers/export", "POST /api/v1/customers/import",
+		"DELETE /api/v1/customers/{id}", "POST /api/v1/customers/{id}/block", "DELETE /api/v1/customers/{id}/block",
+		"POST /customers.removeById", "POST /customers.blockById", "POST /customers.unblockById",
+		"POST /api/v1/customers/bulk/block", "POST /api/v1/customers/bulk/unblock", "POST /api/v1/customers/bulk/delete",
+		"POST /api/v1/products", "DELETE /api/v1/products/{id}",
+		"POST /api/v1/products/{id}/attachments", "DELETE /api/v1/products/{id}/attachments/{attachmentId}",
+		"POST /api/v1/loans/{id}/approve", "POST /api/v1/loans/{id}/reject", "POST /api/v1/loans/{id}/disburse",
+		"POST /api/v1/rates",
+		"POST /api/v1/customers/{id}/kyc/verify", "POST /api/v1/customers/{id}/kyc/reject",
+		"PUT /api/v1/cards/{id}/limit", "DELETE /api/v1/cards/{id}/block",
+		"POST /api/v1/sales", "GET /api/v1/managers/{id}/sales", "GET /api/v1/sales/reports/managers",
+		"GET /api/v1/sales/reports/departments", "GET /api/v1/sales/reports/top",
+		"GET /api/v1/customers/{id}/profile", "GET /api/v1/customers/{id}/documents",
+		"GET /api/v1/customers/{id}/documents/{documentId}", "GET /api/v1/profiles/pending",
+	} {
+		op := described[key]
+		op.Security = []map[string][]string{{"manager": {}}}
+		op.Responses["401"] = text("Нет клиентского сертификата менеджера или неверные логин и пароль")
+	}
+	// маршруты, закрытые Server.customerToken
+	transfer := described["POST /api/v1/cards/transfer"]
+	transfer.Security = []map[string][]string{{"customer": {}}}
+	transfer.Responses["401"] = text("Нет токена покупателя или он недействителен")
+	transfer.Responses["403"] = fail("Карта from принадлежит другому покупателю")
+	// маршруты, закрытые Server.customerOrManager
+	for _, key := range []string{
+		"PUT /api/v1/customers/{id}/profile", "POST /api/v1/customers/{id}/documents",
+	} {
+		op := described[key]
+		op.Security = []map[string][]string{{"customer": {}}, {"manager": {}}}
+		op.Responses["401"] = text("Нет токена покупателя или данных менеджера")
+		op.Responses["403"] = text("Данные другого покупателя")
+	}
+	return described
+}
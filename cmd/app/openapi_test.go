@@ -0,0 +1,111 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/metrics"
+	"github.com/az1zcheckit/crud/pkg/openapi"
+	"github.com/az1zcheckit/crud/pkg/ratelimit"
+	"github.com/gorilla/mux"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	limiter, err := ratelimit.New(ratelimit.NewMemoryStore(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		mux:     mux.NewRouter(),
+		metrics: metrics.New(nil),
+		limiter: limiter,
+		legacyOpts: LegacyOptions{
+			Enabled:    true,
+			Deprecated: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			Sunset:     time.Date(2027, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	s.Init()
+	return s
+}
+
+// TestOpenAPICoversRoutes падает, если маршрут зарегистрирован в Init, но не описан в operations.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	s := newTestServer(t)
+	doc, err := s.buildOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registered := map[string]bool{}
+	err = s.mux.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			// префикс подмаршрутизатора (/api/v1), сами маршруты обходятся отдельно
+			return nil
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			t.Errorf("route without path template: %v", err)
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			t.Errorf("route %s without methods", template)
+			return nil
+		}
+		for _, method := range methods {
+			registered[method+" "+openapi.Path(template)] = true
+			if !doc.Has(method, template) {
+				t.Errorf("route %s %s is missing from the OpenAPI spec", method, template)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range doc.Operations() {
+		if !registered[op] {
+			t.Errorf("operation %s is described but not registered", op)
+		}
+	}
+	for key := range operations(openapi.New(openapi.Info{})) {
+		if !registered[key] {
+			t.Errorf("operation %s is described but not registered", key)
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	s := newTestServer(t)
+
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d", recorder.Code)
+	}
+	var doc openapi.Document
+	err := json.Unmarshal(recorder.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("got openapi %q", doc.OpenAPI)
+	}
+	for _, name := range []string{"Customer", "Token", "ResponceOk", "ResponceFail"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is missing", name)
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("docs page: got status %d", recorder.Code)
+	}
+}
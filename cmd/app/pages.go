@@ -0,0 +1,87 @@
+package app
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+// flashCookie - сообщение, которое показывается на следующей странице веб-интерфейса.
+const flashCookie = "flash"
+
+// flash - сообщение об итоге действия: Kind - ok или error.
+type flash struct {
+	Kind    string
+	Message string
+}
+
+// renderPage выводит шаблон tmpl (с общим шаблоном layout) для data.
+func renderPage(writer http.ResponseWriter, request *http.Request, code int, tmpl *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	err := tmpl.ExecuteTemplate(&buf, "layout", data)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(code)
+	_, err = buf.WriteTo(writer)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+	}
+}
+
+// csrfToken - токен для поля csrf_token форм страницы.
+func (s *Server) csrfToken(request *http.Request) string {
+	if s.csrf == nil {
+		return ""
+	}
+	return s.csrf.Token(request)
+}
+
+// setFlash сохраняет сообщение для следующей страницы.
+func setFlash(writer http.ResponseWriter, request *http.Request, kind string, message string) {
+	http.SetCookie(writer, &http.Cookie{
+		Name:     flashCookie,
+		Value:    url.QueryEscape(kind + ":" + message),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// popFlash возвращает сохранённое сообщение и удаляет его.
+func popFlash(writer http.ResponseWriter, request *http.Request) *flash {
+	cookie, err := request.Cookie(flashCookie)
+	if err != nil {
+		return nil
+	}
+	clearCookie(writer, request, flashCookie, "/")
+	value, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || (parts[0] != "ok" && parts[0] != "error") {
+		return nil
+	}
+	return &flash{Kind: parts[0], Message: parts[1]}
+}
+
+// clearCookie удаляет cookie name, выставленную для path.
+func clearCookie(writer http.ResponseWriter, request *http.Request, name string, path string) {
+	http.SetCookie(writer, &http.Cookie{
+		Name:     name,
+		Path:     path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   request.TLS != nil,
+	})
+}
@@ -0,0 +1,310 @@
+package app
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/accesslog"
+	"github.com/az1zcheckit/crud/pkg/customers"
+	"github.com/az1zcheckit/crud/pkg/kyc"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/security"
+	"github.com/gorilla/mux"
+)
+
+//go:embed portal
+var portalFiles embed.FS
+
+const (
+	// portalSessionCookie - cookie с токеном покупателя; входит в список cookie, которые проверяет CSRF.
+	portalSessionCookie = "customer_session"
+	// portalSessionTTL - срок cookie, как срок токена в customers_tokens по умолчанию.
+	portalSessionTTL = time.Hour
+	// minPasswordLength - минимальная длина нового пароля.
+	minPasswordLength = 8
+)
+
+// portalTemplates - страницы кабинета покупателя, каждая вместе с общим шаблоном layout.html.
+var portalTemplates = func() map[string]*template.Template {
+	pages := make(map[string]*template.Template)
+	for _, name := range []string{"login.html", "profile.html", "sessions.html"} {
+		pages[name] = template.Must(template.ParseFS(portalFiles, "portal/layout.html", "portal/"+name))
+	}
+	return pages
+}()
+
+// portalView - данные страницы кабинета покупателя.
+type portalView struct {
+	Title    string
+	Customer *customers.Customer
+	CSRF     string
+	Flash    *flash
+	Error    string
+	Phone    string
+
+	Profile  *kyc.Profile
+	Sessions []*customers.Session
+	Current  int64
+}
+
+// portalCustomer - покупатель, вошедший в кабинет, и токен его сессии.
+type portalCustomer struct {
+	*customers.Customer
+	token string
+}
+
+// initPortal регистрирует кабинет покупателя.
+func (s *Server) initPortal() {
+	s.mux.HandleFunc("/portal/", s.customerOnly(s.handlePortalProfile)).Methods(GET)
+	s.mux.HandleFunc("/portal/login", s.handlePortalLoginForm).Methods(GET)
+	s.mux.HandleFunc("/portal/login", s.handlePortalLogin).Methods(POST)
+	s.mux.HandleFunc("/portal/logout", s.customerOnly(s.handlePortalLogout)).Methods(POST)
+	s.mux.HandleFunc("/portal/password", s.customerOnly(s.handlePortalPassword)).Methods(POST)
+	s.mux.HandleFunc("/portal/sessions", s.customerOnly(s.handlePortalSessions)).Methods(GET)
+	s.mux.HandleFunc("/portal/sessions/others/revoke", s.customerOnly(s.handlePortalRevokeOthers)).Methods(POST)
+	s.mux.HandleFunc("/portal/sessions/{id}/revoke", s.customerOnly(s.handlePortalRevoke)).Methods(POST)
+	s.mux.PathPrefix("/portal/static/").Handler(portalStaticHandler()).Methods(GET)
+}
+
+// portalStaticHandler отдаёт стили кабинета.
+func portalStaticHandler() http.Handler {
+	files, err := fs.Sub(portalFiles, "portal/static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/portal/static", http.FileServer(http.FS(files)))
+}
+
+// portalHandler - обработчик страницы кабинета, доступной только после входа.
+type portalHandler func(writer http.ResponseWriter, request *http.Request, customer *portalCustomer)
+
+// customerOnly пропускает к handler только запросы активного покупателя с действующим токеном
+// в cookie, остальных отправляет на страницу входа.
+func (s *Server) customerOnly(handler portalHandler) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		cookie, err := request.Cookie(portalSessionCookie)
+		if err != nil {
+			http.Redirect(writer, request, "/portal/login", http.StatusSeeOther)
+			return
+		}
+		id, err := s.securitySvc.AuthForCustomer(request.Context(), cookie.Value)
+		if errors.Is(err, security.ErrNoSuchUser) || errors.Is(err, security.ErrExpiredToken) {
+			clearCookie(writer, request, portalSessionCookie, "/portal")
+			setFlash(writer, request, "error", "Сессия истекла, войдите снова")
+			http.Redirect(writer, request, "/portal/login", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			logger.Ctx(request.Context()).Error(err)
+			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		item, err := s.customersSvc.ByID(request.Context(), id)
+		if err != nil {
+			logger.Ctx(request.Context()).Error(err)
+			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if !item.Active {
+			logger.Ctx(request.Context()).Warnw("blocked customer session", "customer_id", id)
+			clearCookie(writer, request, portalSessionCookie, "/portal")
+			setFlash(writer, request, "error", "Доступ заблокирован, обратитесь в банк")
+			http.Redirect(writer, request, "/portal/login", http.StatusSeeOther)
+			return
+		}
+		accesslog.SetPrincipal(request.Context(), "customer:"+strconv.FormatInt(id, 10))
+		handler(writer, request, &portalCustomer{Customer: item, token: cookie.Value})
+	}
+}
+
+// renderPortal выводит страницу кабинета name.
+func (s *Server) renderPortal(writer http.ResponseWriter, request *http.Request, code int, name string, view *portalView) {
+	view.CSRF = s.csrfToken(request)
+	view.Flash = popFlash(writer, request)
+	renderPage(writer, request, code, portalTemplates[name], view)
+}
+
+func (s *Server) handlePortalLoginForm(writer http.ResponseWriter, request *http.Request) {
+	s.renderPortal(writer, request, http.StatusOK, "login.html", &portalView{Title: "Вход"})
+}
+
+// handlePortalLogin выдаёт покупателю токен, как POST /api/v1/customers/token,
+// но кладёт его в cookie, недоступную из JavaScript.
+func (s *Server) handlePortalLogin(writer http.ResponseWriter, request *http.Request) {
+	phone := strings.TrimSpace(request.PostFormValue("phone"))
+	token, err := s.customersSvc.TokenForCustomer(request.Context(), phone, request.PostFormValue("password"))
+	if errors.Is(err, customers.ErrNoSuchUser) || errors.Is(err, customers.ErrInvalidPassword) {
+		s.metrics.FailedLogins.Inc()
+		logger.Ctx(request.Context()).Warnw("failed login", "login", phone)
+		s.renderPortal(writer, request, http.StatusUnauthorized, "login.html", &portalView{
+			Title: "Вход",
+			Phone: phone,
+			Error: "Неверный телефон или пароль",
+		})
+		return
+	}
+	if errors.Is(err, customers.ErrBlocked) {
+		logger.Ctx(request.Context()).Warnw("blocked customer login", "login", phone)
+		s.renderPortal(writer, request, http.StatusForbidden, "login.html", &portalView{
+			Title: "Вход",
+			Phone: phone,
+			Error: "Доступ заблокирован, обратитесь в банк",
+		})
+		return
+	}
+	if errors.Is(err, customers.ErrNotVerified) {
+		s.renderPortal(writer, request, http.StatusForbidden, "login.html", &portalView{
+			Title: "Вход",
+			Phone: phone,
+			Error: "Профиль ещё не прошёл проверку, войти можно будет после неё",
+		})
+		return
+	}
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	s.metrics.TokensIssued.Inc()
+
+	http.SetCookie(writer, &http.Cookie{
+		Name:     portalSessionCookie,
+		Value:    token,
+		Path:     "/portal",
+		MaxAge:   int(portalSessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(writer, request, "/portal/", http.StatusSeeOther)
+}
+
+func (s *Server) handlePortalLogout(writer http.ResponseWriter, request *http.Request, customer *portalCustomer) {
+	err := s.customersSvc.RevokeToken(request.Context(), customer.token)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+	}
+	clearCookie(writer, request, portalSessionCookie, "/portal")
+	setFlash(writer, request, "ok", "Вы вышли")
+	http.Redirect(writer, request, "/portal/login", http.StatusSeeOther)
+}
+
+// handlePortalProfile - данные покупателя, статус проверки и смена пароля.
+func (s *Server) handlePortalProfile(writer http.ResponseWriter, request *http.Request, customer *portalCustomer) {
+	profile, err := s.kycSvc.ByCustomer(request.Context(), customer.ID)
+	if err != nil && !errors.Is(err, kyc.ErrNotFound) {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	s.renderPortal(writer, request, http.StatusOK, "profile.html", &portalView{
+		Title:    "Профиль",
+		Customer: customer.Customer,
+		Profile:  profile,
+	})
+}
+
+// handlePortalPassword меняет пароль и завершает остальные сессии покупателя.
+func (s *Server) handlePortalPassword(writer http.ResponseWriter, request *http.Request, customer *portalCustomer) {
+	password := request.PostFormValue("password")
+	switch {
+	case len([]rune(password)) < minPasswordLength:
+		setFlash(writer, request, "error", "Новый пароль должен быть не короче "+strconv.Itoa(minPasswordLength)+" символов")
+		http.Redirect(writer, request, "/portal/", http.StatusSeeOther)
+		return
+	case password != request.PostFormValue("confirm"):
+		setFlash(writer, request, "error", "Пароли не совпадают")
+		http.Redirect(writer, request, "/portal/", http.StatusSeeOther)
+		return
+	}
+
+	err := s.customersSvc.ChangePassword(request.Context(), customer.ID, request.PostFormValue("current"), password)
+	if errors.Is(err, customers.ErrInvalidPassword) {
+		logger.Ctx(request.Context()).Warnw("wrong current password", "customer_id", customer.ID)
+		setFlash(writer, request, "error", "Текущий пароль указан неверно")
+		http.Redirect(writer, request, "/portal/", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		setFlash(writer, request, "error", "Не удалось сменить пароль, попробуйте позже")
+		http.Redirect(writer, request, "/portal/", http.StatusSeeOther)
+		return
+	}
+
+	// с новым паролем старые токены с других устройств не должны продолжать работать
+	revoked, err := s.customersSvc.RevokeOtherSessions(request.Context(), customer.ID, customer.token)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+	}
+	logger.Ctx(request.Context()).Infow("customer password changed", "customer_id", customer.ID, "revoked_sessions", revoked)
+	setFlash(writer, request, "ok", "Пароль изменён, остальные сессии завершены")
+	http.Redirect(writer, request, "/portal/", http.StatusSeeOther)
+}
+
+// handlePortalSessions - действующие сессии покупателя.
+func (s *Server) handlePortalSessions(writer http.ResponseWriter, request *http.Request, customer *portalCustomer) {
+	items, err := s.customersSvc.Sessions(request.Context(), customer.ID)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	current, err := s.customersSvc.SessionID(request.Context(), customer.token)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	s.renderPortal(writer, request, http.StatusOK, "sessions.html", &portalView{
+		Title:    "Сессии",
+		Customer: customer.Customer,
+		Sessions: items,
+		Current:  current,
+	})
+}
+
+// handlePortalRevoke завершает одну сессию; завершение текущей - то же, что выход.
+func (s *Server) handlePortalRevoke(writer http.ResponseWriter, request *http.Request, customer *portalCustomer) {
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	current, err := s.customersSvc.SessionID(request.Context(), customer.token)
+	if err == nil && current == id {
+		s.handlePortalLogout(writer, request, customer)
+		return
+	}
+
+	err = s.customersSvc.RevokeSession(request.Context(), customer.ID, id)
+	switch {
+	case errors.Is(err, customers.ErrNotFound):
+		setFlash(writer, request, "error", "Сессия уже завершена")
+	case err != nil:
+		logger.Ctx(request.Context()).Error(err)
+		setFlash(writer, request, "error", "Не удалось завершить сессию, попробуйте позже")
+	default:
+		setFlash(writer, request, "ok", "Сессия завершена")
+	}
+	http.Redirect(writer, request, "/portal/sessions", http.StatusSeeOther)
+}
+
+// handlePortalRevokeOthers завершает все сессии, кроме текущей.
+func (s *Server) handlePortalRevokeOthers(writer http.ResponseWriter, request *http.Request, customer *portalCustomer) {
+	revoked, err := s.customersSvc.RevokeOtherSessions(request.Context(), customer.ID, customer.token)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		setFlash(writer, request, "error", "Не удалось завершить сессии, попробуйте позже")
+	} else {
+		setFlash(writer, request, "ok", "Завершено сессий: "+strconv.FormatInt(revoked, 10))
+	}
+	http.Redirect(writer, request, "/portal/sessions", http.StatusSeeOther)
+}
@@ -0,0 +1,225 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/az1zcheckit/crud/pkg/binding"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/products"
+)
+
+// respondProductsError отправляет ответ, соответствующий ошибке сервиса продуктов.
+func respondProductsError(writer http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, products.ErrNotFound), errors.Is(err, products.ErrAttachmentNotFound):
+		respondFail(writer, request, http.StatusNotFound, err.Error())
+	case errors.Is(err, products.ErrInvalidProduct):
+		respondFail(writer, request, http.StatusBadRequest, err.Error())
+	case errors.Is(err, products.ErrAttachmentTooLarge):
+		respondFail(writer, request, http.StatusRequestEntityTooLarge, err.Error())
+	default:
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// handleGetAllProducts - каталог продуктов (фильтры category и available=true).
+func (s *Server) handleGetAllProducts(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	items, err := s.productsSvc.All(request.Context(), query.Get("category"), query.Get("available") == "true")
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleGetProductByID - нахождение продукта по id.
+func (s *Server) handleGetProductByID(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.productsSvc.ByID(request.Context(), id)
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, item)
+}
+
+// handleSaveProduct - создаёт или обновляет продукт.
+func (s *Server) handleSaveProduct(writer http.ResponseWriter, request *http.Request) {
+	var item *products.Product
+	err := binding.Decode(request, &item)
+	if err != nil || item == nil {
+		respondDecodeError(writer, request, err)
+		return
+	}
+
+	product, err := s.productsSvc.Save(request.Context(), item)
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, product)
+}
+
+// handleRemoveProduct - удаляет продукт.
+func (s *Server) handleRemoveProduct(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err = s.productsSvc.RemoveByID(request.Context(), id)
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+}
+
+// handleGetProductPrices - история цен продукта.
+func (s *Server) handleGetProductPrices(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.productsSvc.Prices(request.Context(), id)
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleGetCustomerProducts - продукты, которые держит покупатель.
+func (s *Server) handleGetCustomerProducts(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.productsSvc.ByCustomer(request.Context(), id)
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleAddProductAttachment - загружает вложение продукта (multipart/form-data, поле file).
+func (s *Server) handleAddProductAttachment(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	request.Body = http.MaxBytesReader(writer, request.Body, products.MaxAttachmentSize+1<<20)
+	file, header, err := request.FormFile("file")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	item, err := s.productsSvc.AddAttachment(request.Context(), id, header.Filename, file)
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusCreated, item)
+}
+
+// handleGetProductAttachments - список вложений продукта.
+func (s *Server) handleGetProductAttachments(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.productsSvc.Attachments(request.Context(), id)
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleGetProductAttachment - скачивание вложения продукта.
+func (s *Server) handleGetProductAttachment(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	attachmentID, err := idFromRequest(request, "attachmentId")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, file, err := s.productsSvc.OpenAttachment(request.Context(), id, attachmentID)
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+	defer file.Close()
+
+	// в браузере открываются только безопасные типы, остальное - только скачивание,
+	// чтобы загруженный HTML или скрипт не выполнился на origin API
+	contentType, disposition := item.ContentType, "inline"
+	if !item.Inline() {
+		contentType, disposition = "application/octet-stream", "attachment"
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Length", strconv.FormatInt(item.Size, 10))
+	writer.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": item.Name}))
+	_, err = io.Copy(writer, file)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+	}
+}
+
+// handleRemoveProductAttachment - удаляет вложение продукта.
+func (s *Server) handleRemoveProductAttachment(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	attachmentID, err := idFromRequest(request, "attachmentId")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err = s.productsSvc.RemoveAttachment(request.Context(), id, attachmentID)
+	if err != nil {
+		respondProductsError(writer, request, err)
+		return
+	}
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/binding"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/money"
+	"github.com/az1zcheckit/crud/pkg/rates"
+)
+
+// Conversion - результат пересчёта суммы в другую валюту.
+type Conversion struct {
+	From money.Money `json:"from"`
+	To   money.Money `json:"to"`
+	Rate *rates.Rate `json:"rate"`
+}
+
+// respondRatesError отправляет ответ, соответствующий ошибке сервиса курсов.
+func respondRatesError(writer http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, rates.ErrNoRate):
+		respondFail(writer, request, http.StatusNotFound, err.Error())
+	case errors.Is(err, rates.ErrInvalidRate), errors.Is(err, money.ErrUnknownCurrency), errors.Is(err, money.ErrInvalidAmount):
+		respondFail(writer, request, http.StatusBadRequest, err.Error())
+	default:
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// dateFromRequest читает дату из параметра date, по умолчанию - текущий момент.
+func dateFromRequest(request *http.Request) (time.Time, error) {
+	param := request.URL.Query().Get("date")
+	if param == "" {
+		return time.Now(), nil
+	}
+	return time.Parse(dateLayout, param)
+}
+
+// handleGetRates - курсы валют на дату (параметр date).
+func (s *Server) handleGetRates(writer http.ResponseWriter, request *http.Request) {
+	date, err := dateFromRequest(request)
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.ratesSvc.On(request.Context(), date)
+	if err != nil {
+		respondRatesError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleSaveRates - сохраняет курс (JSON) или загружает курсы из CSV (Content-Type: text/csv).
+func (s *Server) handleSaveRates(writer http.ResponseWriter, request *http.Request) {
+	contentType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if contentType == "text/csv" {
+		count, err := s.ratesSvc.Load(request.Context(), request.Body)
+		if err != nil {
+			respondRatesError(writer, request, err)
+			return
+		}
+		respond(writer, request, http.StatusOK, map[string]int{"loaded": count})
+		return
+	}
+
+	var item *rates.Rate
+	err := binding.Decode(request, &item)
+	if err != nil || item == nil {
+		respondDecodeError(writer, request, err)
+		return
+	}
+
+	rate, err := s.ratesSvc.Save(request.Context(), item)
+	if err != nil {
+		respondRatesError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, rate)
+}
+
+// handleConvert - пересчёт суммы (параметры amount, from, to, date).
+func (s *Server) handleConvert(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	amount, err := money.Parse(query.Get("amount"), query.Get("from"))
+	if err != nil {
+		respondRatesError(writer, request, err)
+		return
+	}
+	date, err := dateFromRequest(request)
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	converted, rate, err := s.ratesSvc.Convert(request.Context(), amount, query.Get("to"), date)
+	if err != nil {
+		respondRatesError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, &Conversion{From: amount, To: converted, Rate: rate})
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/binding"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/sales"
+)
+
+// dateLayout - формат дат в параметрах запросов.
+const dateLayout = "2006-01-02"
+
+// periodFromRequest читает период отчёта из параметров from и to (включительно).
+// По умолчанию берётся текущий месяц.
+func periodFromRequest(request *http.Request) (sales.Period, error) {
+	period := sales.CurrentMonth(time.Now())
+	query := request.URL.Query()
+	if from := query.Get("from"); from != "" {
+		date, err := time.ParseInLocation(dateLayout, from, time.Local)
+		if err != nil {
+			return period, err
+		}
+		period.From = date
+	}
+	if to := query.Get("to"); to != "" {
+		date, err := time.ParseInLocation(dateLayout, to, time.Local)
+		if err != nil {
+			return period, err
+		}
+		period.To = date.AddDate(0, 0, 1)
+	}
+	return period, nil
+}
+
+// respondSalesError отправляет ответ, соответствующий ошибке сервиса продаж.
+func respondSalesError(writer http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, sales.ErrNoSuchManager), errors.Is(err, sales.ErrNoSuchCustomer), errors.Is(err, sales.ErrNoSuchProduct):
+		respondFail(writer, request, http.StatusNotFound, err.Error())
+	case errors.Is(err, sales.ErrInvalidSale), errors.Is(err, sales.ErrInvalidPeriod):
+		respondFail(writer, request, http.StatusBadRequest, err.Error())
+	default:
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// wantsCSV возвращает true, если отчёт запрошен в формате CSV.
+func wantsCSV(request *http.Request) bool {
+	return request.URL.Query().Get("format") == "csv"
+}
+
+// handleRecordSale - сохраняет продажу менеджера, прошедшего аутентификацию;
+// managerId из тела запроса не используется.
+func (s *Server) handleRecordSale(writer http.ResponseWriter, request *http.Request) {
+	manager, ok := requestManager(writer, request)
+	if !ok {
+		return
+	}
+	var item *sales.Sale
+	err := binding.Decode(request, &item)
+	if err != nil || item == nil {
+		respondDecodeError(writer, request, err)
+		return
+	}
+	item.ManagerID = manager.ID
+
+	sale, err := s.salesSvc.Record(request.Context(), item)
+	if err != nil {
+		respondSalesError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusCreated, sale)
+}
+
+// handleGetManagerSales - продажи менеджера за период.
+func (s *Server) handleGetManagerSales(writer http.ResponseWriter, request *http.Request) {
+	id, err := idFromRequest(request, "id")
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	period, err := periodFromRequest(request)
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.salesSvc.ByManager(request.Context(), id, period)
+	if err != nil {
+		respondSalesError(writer, request, err)
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleManagersReport - выполнение плана по менеджерам (JSON или CSV).
+func (s *Server) handleManagersReport(writer http.ResponseWriter, request *http.Request) {
+	period, err := periodFromRequest(request)
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.salesSvc.Managers(request.Context(), period, request.URL.Query().Get("department"))
+	if err != nil {
+		respondSalesError(writer, request, err)
+		return
+	}
+	if wantsCSV(request) {
+		writer.Header().Set("Content-Type", "text/csv")
+		writer.Header().Set("Content-Disposition", `attachment; filename="managers.csv"`)
+		err = sales.WriteManagersCSV(writer, items)
+		if err != nil {
+			logger.Ctx(request.Context()).Error(err)
+		}
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleDepartmentsReport - выполнение плана по отделам (JSON или CSV).
+func (s *Server) handleDepartmentsReport(writer http.ResponseWriter, request *http.Request) {
+	period, err := periodFromRequest(request)
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.salesSvc.Departments(request.Context(), period)
+	if err != nil {
+		respondSalesError(writer, request, err)
+		return
+	}
+	if wantsCSV(request) {
+		writer.Header().Set("Content-Type", "text/csv")
+		writer.Header().Set("Content-Disposition", `attachment; filename="departments.csv"`)
+		err = sales.WriteDepartmentsCSV(writer, items)
+		if err != nil {
+			logger.Ctx(request.Context()).Error(err)
+		}
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
+
+// handleTopReport - лучшие менеджеры по выполнению плана (JSON или CSV).
+func (s *Server) handleTopReport(writer http.ResponseWriter, request *http.Request) {
+	period, err := periodFromRequest(request)
+	if err != nil {
+		logger.Ctx(request.Context()).Warn(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if param := request.URL.Query().Get("limit"); param != "" {
+		limit, err = strconv.Atoi(param)
+		if err != nil || limit <= 0 {
+			http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	items, err := s.salesSvc.Top(request.Context(), period, limit)
+	if err != nil {
+		respondSalesError(writer, request, err)
+		return
+	}
+	if wantsCSV(request) {
+		writer.Header().Set("Content-Type", "text/csv")
+		writer.Header().Set("Content-Disposition", `attachment; filename="top.csv"`)
+		err = sales.WriteManagersCSV(writer, items)
+		if err != nil {
+			logger.Ctx(request.Context()).Error(err)
+		}
+		return
+	}
+	respond(writer, request, http.StatusOK, items)
+}
@@ -1,15 +1,30 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 	"strconv"
 
+	"github.com/az1zcheckit/crud/cmd/app/middleware"
+	"github.com/az1zcheckit/crud/pkg/accesslog"
+	"github.com/az1zcheckit/crud/pkg/binding"
+	"github.com/az1zcheckit/crud/pkg/cards"
 	"github.com/az1zcheckit/crud/pkg/customers"
+	"github.com/az1zcheckit/crud/pkg/health"
+	"github.com/az1zcheckit/crud/pkg/kyc"
+	"github.com/az1zcheckit/crud/pkg/loans"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/metrics"
+	"github.com/az1zcheckit/crud/pkg/products"
+	"github.com/az1zcheckit/crud/pkg/ratelimit"
+	"github.com/az1zcheckit/crud/pkg/rates"
+	"github.com/az1zcheckit/crud/pkg/sales"
 	"github.com/az1zcheckit/crud/pkg/security"
+	"github.com/az1zcheckit/crud/pkg/tracing"
 	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
 var ErrNotFound = errors.New("item not found")
@@ -31,6 +46,22 @@ type Server struct {
 	mux          *mux.Router
 	customersSvc *customers.Service
 	securitySvc  *security.Service
+	cardsSvc     *cards.Service
+	salesSvc     *sales.Service
+	productsSvc  *products.Service
+	loansSvc     *loans.Service
+	ratesSvc     *rates.Service
+	kycSvc       *kyc.Service
+	checker      *health.Checker
+	metrics      *metrics.Metrics
+	log          *zap.Logger
+	limiter      *ratelimit.Limiter
+	csrf         *middleware.CSRF
+	handler      http.Handler
+	openAPI      []byte
+	legacyOpts   LegacyOptions
+	// legacy - устаревший маршрут ("МЕТОД шаблон") и маршрут, который его заменяет.
+	legacy map[string]string
 }
 
 // Token..
@@ -58,91 +89,334 @@ type ResponceFail struct {
 }
 
 // NewServer - функция-конструктор для создания сервера.
-func NewServer(mux *mux.Router, customersSvc *customers.Service, securitySvc *security.Service) *Server {
-	return &Server{mux: mux, customersSvc: customersSvc, securitySvc: securitySvc}
+func NewServer(
+	mux *mux.Router,
+	customersSvc *customers.Service,
+	securitySvc *security.Service,
+	cardsSvc *cards.Service,
+	salesSvc *sales.Service,
+	productsSvc *products.Service,
+	loansSvc *loans.Service,
+	ratesSvc *rates.Service,
+	kycSvc *kyc.Service,
+	checker *health.Checker,
+	metrics *metrics.Metrics,
+	log *zap.Logger,
+	accessLog *accesslog.Logger,
+	limiter *ratelimit.Limiter,
+	cors middleware.CORSOptions,
+	secure middleware.SecureHeadersOptions,
+	csrf *middleware.CSRF,
+	legacyOpts LegacyOptions,
+) *Server {
+	// CORS обрабатывается до роутера: на preflight-запросы OPTIONS нет маршрутов
+	var handler http.Handler = csrf.Middleware(mux)
+	handler = middleware.ClientCert(securitySvc.ManagerIDByLogin)(handler)
+	handler = middleware.CORS(cors)(handler)
+	handler = middleware.SecureHeaders(secure)(handler)
+	// id запроса назначается до журнала запросов, чтобы попасть в его записи
+	handler = middleware.RequestID(log)(accessLog.Middleware(handler))
+	return &Server{
+		mux:          mux,
+		customersSvc: customersSvc,
+		securitySvc:  securitySvc,
+		cardsSvc:     cardsSvc,
+		salesSvc:     salesSvc,
+		productsSvc:  productsSvc,
+		loansSvc:     loansSvc,
+		ratesSvc:     ratesSvc,
+		kycSvc:       kycSvc,
+		checker:      checker,
+		metrics:      metrics,
+		log:          log,
+		limiter:      limiter,
+		csrf:         csrf,
+		handler:      handler,
+		legacyOpts:   legacyOpts,
+	}
 }
 
 func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	s.mux.ServeHTTP(writer, request)
+	s.handler.ServeHTTP(writer, request)
 }
 
 const (
 	GET    = "GET"
 	POST   = "POST"
+	PUT    = "PUT"
 	DELETE = "DELETE"
 )
 
-// save done
-// active done
-// block done
-// unblock done
+// apiPrefix - префикс текущей версии API.
+const apiPrefix = "/api/v1"
+
+// route - маршрут API: path задаётся относительно apiPrefix,
+// legacy - прежний путь без версии, который пока обслуживается как устаревший; пусто, если его нет.
+type route struct {
+	method  string
+	path    string
+	legacy  string
+	handler http.HandlerFunc
+}
+
+// routes возвращает маршруты API. Порядок важен: /customers/active, /customers/export
+// и /customers/bulk/... раньше /customers/{id}. Импорт, выгрузка, блокировка и удаление покупателей,
+// продажи и отчёты по ним, решения по заявкам и проверке покупателей, массовые операции, курсы и изменения
+// каталога, лимиты и разблокировка карт, профили и документы покупателей доступны
+// только менеджерам; профиль и документы загружает сам покупатель по токену или менеджер,
+// переводы выполняет только владелец карты по токену.
+func (s *Server) routes() []route {
+	return []route{
+		{GET, "/customers", "/customers", s.handleGetAllCustomers},
+		{GET, "/customers/active", "/customers/active", s.handleGetAllActiveCustomers},
+		{GET, "/customers/export", "", s.managerOnly(s.handleExportCustomers)},
+		{GET, "/customers/{id}", "/customers/{id}", s.handleGetCustomersByID},
+		{POST, "/customers", "/customers", s.handleSaveCustomers},
+		{POST, "/customers/import", "", s.managerOnly(s.handleImportCustomers)},
+		{POST, "/customers/bulk/block", "", s.managerOnly(s.handleBulkCustomers(customers.BulkBlock))},
+		{POST, "/customers/bulk/unblock", "", s.managerOnly(s.handleBulkCustomers(customers.BulkUnblock))},
+		{POST, "/customers/bulk/delete", "", s.managerOnly(s.handleBulkCustomers(customers.BulkDelete))},
+		{DELETE, "/customers/{id}", "/customers/{id}", s.managerOnly(s.handleRemoveByID)},
+		{POST, "/customers/{id}/block", "/customers/{id}/block", s.managerOnly(s.handleBlockByID)},
+		{DELETE, "/customers/{id}/block", "/customers/{id}/block", s.managerOnly(s.handleUnBlockByID)},
+
+		{POST, "/customers/register", "/api/customers", s.SaveCustomers},
+		{POST, "/customers/token", "/api/customers/token", s.handleGetToken},
+		{POST, "/customers/token/validate", "/api/customers/token/validate", s.handleValidateToken},
+
+		{GET, "/customers/{id}/cards", "/customers/{id}/cards", s.handleGetCustomerCards},
+		{POST, "/customers/{id}/cards", "/customers/{id}/cards", s.handleIssueCard},
+		{POST, "/cards/transfer", "/cards/transfer", s.customerToken(s.handleTransfer)},
+		{GET, "/cards/{id}", "/cards/{id}", s.handleGetCardByID},
+		{PUT, "/cards/{id}/limit", "/cards/{id}/limit", s.managerOnly(s.handleSetCardLimit)},
+		{POST, "/cards/{id}/block", "/cards/{id}/block", s.handleBlockCard},
+		{DELETE, "/cards/{id}/block", "/cards/{id}/block", s.managerOnly(s.handleUnBlockCard)},
+
+		{POST, "/sales", "/sales", s.managerOnly(s.handleRecordSale)},
+		{GET, "/managers/{id}/sales", "/managers/{id}/sales", s.managerOnly(s.handleGetManagerSales)},
+		{GET, "/sales/reports/managers", "/sales/reports/managers", s.managerOnly(s.handleManagersReport)},
+		{GET, "/sales/reports/departments", "/sales/reports/departments", s.managerOnly(s.handleDepartmentsReport)},
+		{GET, "/sales/reports/top", "/sales/reports/top", s.managerOnly(s.handleTopReport)},
+
+		{GET, "/products", "/products", s.handleGetAllProducts},
+		{POST, "/products", "/products", s.managerOnly(s.handleSaveProduct)},
+		{GET, "/products/{id}", "/products/{id}", s.handleGetProductByID},
+		{DELETE, "/products/{id}", "/products/{id}", s.managerOnly(s.handleRemoveProduct)},
+		{GET, "/products/{id}/prices", "/products/{id}/prices", s.handleGetProductPrices},
+		{GET, "/products/{id}/attachments", "/products/{id}/attachments", s.handleGetProductAttachments},
+		{POST, "/products/{id}/attachments", "/products/{id}/attachments", s.managerOnly(s.handleAddProductAttachment)},
+		{GET, "/products/{id}/attachments/{attachmentId}", "/products/{id}/attachments/{attachmentId}", s.handleGetProductAttachment},
+		{DELETE, "/products/{id}/attachments/{attachmentId}", "/products/{id}/attachments/{attachmentId}", s.managerOnly(s.handleRemoveProductAttachment)},
+		{GET, "/customers/{id}/products", "/customers/{id}/products", s.handleGetCustomerProducts},
+
+		{POST, "/loans", "/loans", s.handleSaveLoan},
+		{GET, "/loans/{id}", "/loans/{id}", s.handleGetLoanByID},
+		{GET, "/loans/{id}/schedule", "/loans/{id}/schedule", s.handleLoanSchedule},
+		{POST, "/loans/{id}/submit", "/loans/{id}/submit", s.handleSubmitLoan},
+		{POST, "/loans/{id}/approve", "/loans/{id}/approve", s.managerOnly(s.handleApproveLoan)},
+		{POST, "/loans/{id}/reject", "/loans/{id}/reject", s.managerOnly(s.handleRejectLoan)},
+		{POST, "/loans/{id}/disburse", "/loans/{id}/disburse", s.managerOnly(s.handleDisburseLoan)},
+		{GET, "/customers/{id}/loans", "/customers/{id}/loans", s.handleGetCustomerLoans},
+
+		{GET, "/rates", "/rates", s.handleGetRates},
+		{POST, "/rates", "/rates", s.managerOnly(s.handleSaveRates)},
+		{GET, "/rates/convert", "/rates/convert", s.handleConvert},
+
+		{GET, "/customers/{id}/profile", "/customers/{id}/profile", s.managerOnly(s.handleGetProfile)},
+		{PUT, "/customers/{id}/profile", "/customers/{id}/profile", s.customerOrManager(s.handleSaveProfile)},
+		{POST, "/customers/{id}/kyc/verify", "/customers/{id}/kyc/verify", s.managerOnly(s.handleVerifyProfile)},
+		{POST, "/customers/{id}/kyc/reject", "/customers/{id}/kyc/reject", s.managerOnly(s.handleRejectProfile)},
+		{GET, "/customers/{id}/documents", "/customers/{id}/documents", s.managerOnly(s.handleGetDocuments)},
+		{POST, "/customers/{id}/documents", "/customers/{id}/documents", s.customerOrManager(s.handleAddDocument)},
+		{GET, "/customers/{id}/documents/{documentId}", "/customers/{id}/documents/{documentId}", s.managerOnly(s.handleGetDocument)},
+		{GET, "/profiles/pending", "/profiles/pending", s.managerOnly(s.handleGetPendingProfiles)},
+	}
+}
+
+// managerOnly пропускает к handler только менеджеров: по клиентскому сертификату
+// или Basic-аутентификации. Менеджер доступен через middleware.ManagerFromContext.
+func (s *Server) managerOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return middleware.Basic(s.securitySvc.AuthManager)(handler).ServeHTTP
+}
+
+// requestManager возвращает менеджера, опознанного managerOnly. Если его нет
+// (маршрут не закрыт managerOnly), отвечает 401 и возвращает false.
+func requestManager(writer http.ResponseWriter, request *http.Request) (*middleware.Manager, bool) {
+	manager, ok := middleware.ManagerFromContext(request.Context())
+	if !ok {
+		logger.Ctx(request.Context()).Warn("manager is not authenticated")
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	}
+	return manager, ok
+}
+
+// customerToken пропускает к handler только покупателя с токеном в заголовке Authorization: Bearer.
+// Покупатель доступен через middleware.CustomerFromContext.
+func (s *Server) customerToken(handler http.HandlerFunc) http.HandlerFunc {
+	return middleware.Bearer(s.authCustomer)(handler).ServeHTTP
+}
+
+// customerOrManager пропускает к handler покупателя с токеном в заголовке Authorization: Bearer
+// или менеджера, как managerOnly. Что покупатель обращается к своим данным, проверяет requestOwner.
+func (s *Server) customerOrManager(handler http.HandlerFunc) http.HandlerFunc {
+	customer := s.customerToken(handler)
+	manager := s.managerOnly(handler)
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if _, ok := middleware.ManagerFromContext(request.Context()); !ok && middleware.BearerToken(request) != "" {
+			customer(writer, request)
+			return
+		}
+		manager(writer, request)
+	}
+}
+
+// authCustomer возвращает id покупателя по токену.
+func (s *Server) authCustomer(ctx context.Context, token string) (int64, error) {
+	return s.securitySvc.AuthForCustomer(ctx, token)
+}
+
+// requestOwner проверяет, что запрос выполняет менеджер или сам покупатель customerID.
+// Чужому покупателю отвечает 403, неопознанному клиенту - 401, и возвращает false.
+func requestOwner(writer http.ResponseWriter, request *http.Request, customerID int64) bool {
+	if _, ok := middleware.ManagerFromContext(request.Context()); ok {
+		return true
+	}
+	id, ok := middleware.CustomerFromContext(request.Context())
+	if !ok {
+		logger.Ctx(request.Context()).Warn("customer is not authenticated")
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+	if id != customerID {
+		logger.Ctx(request.Context()).Warnw("access to another customer", "customer_id", id, "owner_id", customerID)
+		http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return false
+	}
+	return true
+}
 
 // Init инициализирует сервер (регистрирует все Handler'ы)
 func (s *Server) Init() {
-	//s.mux.HandleFunc("/customers.getAll", s.handleGetAllCustomers)
-	s.mux.HandleFunc("/customers", s.handleGetAllCustomers).Methods(GET)
-	//s.mux.HandleFunc("/customers.getAllActive", s.handleGetAllActiveCustomers)
-	s.mux.HandleFunc("/customers/active", s.handleGetAllActiveCustomers).Methods(GET)
-	///s.mux.HandleFunc("/customers.getById", s.handleGetCustomerByID)
-	s.mux.HandleFunc("/customers/{id}", s.handleGetCustomersByID).Methods(GET)
-	//s.mux.HandleFunc("/customers.save", s.handleSaveCustomers)
-	s.mux.HandleFunc("/customers", s.handleSaveCustomers).Methods(POST)
-	//s.mux.HandleFunc("/customers.removeById", s.handleRemoveByID)
-	s.mux.HandleFunc("/customers/{id}", s.handleRemoveByID).Methods(DELETE)
-	//s.mux.HandleFunc("/customers.blockById", s.handleBlockByID)
-	s.mux.HandleFunc("/customers/{id}/block", s.handleBlockByID).Methods(POST)
-	//s.mux.HandleFunc("/customers.unblockById", s.handleUnBlockByID)
-	s.mux.HandleFunc("/customers/{id}/block", s.handleUnBlockByID).Methods(DELETE)
-	// s.mux.Use(middleware.Basic(s.securitySvc.Auth))
-
-	s.mux.HandleFunc("/api/customers", s.SaveCustomers).Methods(POST)
-	s.mux.HandleFunc("/api/customers/token", s.handleGetToken).Methods(POST)
-	s.mux.HandleFunc("/api/customers/token/validate", s.handleValidateToken).Methods(POST)
+	s.mux.Use(accesslog.Route)
+	s.mux.Use(tracing.Middleware)
+	s.mux.Use(s.metrics.Middleware)
+	s.mux.Use(s.limiter.Middleware)
+
+	routes := s.routes()
+	api := s.mux.PathPrefix(apiPrefix).Subrouter()
+	for _, route := range routes {
+		api.HandleFunc(route.path, route.handler).Methods(route.method)
+	}
+	if s.legacyOpts.Enabled {
+		s.initLegacy(routes)
+	}
+
+	s.mux.HandleFunc("/healthz", s.handleHealth).Methods(GET)
+	s.mux.HandleFunc("/readyz", s.handleReady).Methods(GET)
+	s.mux.HandleFunc("/version", s.handleVersion).Methods(GET)
+	s.mux.Handle("/metrics", s.metrics.Handler()).Methods(GET)
+	s.mux.HandleFunc("/openapi.json", s.handleOpenAPI).Methods(GET)
+	s.mux.PathPrefix("/docs/").Handler(docsHandler()).Methods(GET)
+	s.initAdmin()
+	s.initPortal()
+
+	// спецификация строится по уже зарегистрированным маршрутам
+	doc, err := s.buildOpenAPI()
+	if err == nil {
+		s.openAPI, err = json.MarshalIndent(doc, "", "  ")
+	}
+	if err != nil {
+		zap.S().Error(err)
+	}
+}
+
+// idFromRequest достаёт числовой параметр пути (например, {id}).
+func idFromRequest(request *http.Request, name string) (int64, error) {
+	param, ok := mux.Vars(request)[name]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return strconv.ParseInt(param, 10, 64)
+}
+
+// respond отправляет item с кодом code в формате, выбранном по заголовку Accept (JSON или msgpack).
+func respond(writer http.ResponseWriter, request *http.Request, code int, item interface{}) {
+	contentType := binding.Negotiate(request)
+	data, err := binding.Marshal(contentType, item)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Add("Vary", "Accept")
+	writer.WriteHeader(code)
+	_, err = writer.Write(data)
+	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
+	}
+}
+
+// respondFail отправляет ResponceFail с причиной reason.
+func respondFail(writer http.ResponseWriter, request *http.Request, code int, reason string) {
+	respond(writer, request, code, &ResponceFail{Status: "fail", Reason: reason})
+}
+
+// respondDecodeError отвечает на тело запроса, которое не удалось разобрать:
+// 415 для неподдерживаемого Content-Type, иначе 400.
+func respondDecodeError(writer http.ResponseWriter, request *http.Request, err error) {
+	logger.Ctx(request.Context()).Warn(err)
+	code := http.StatusBadRequest
+	if errors.Is(err, binding.ErrUnsupportedMediaType) {
+		code = http.StatusUnsupportedMediaType
+	}
+	http.Error(writer, http.StatusText(code), code)
 }
 
 func (s *Server) handleGetToken(writer http.ResponseWriter, request *http.Request) {
 	var auth *security.Auth
 	var tok Token
-	err := json.NewDecoder(request.Body).Decode(&auth)
-	log.Print(auth)
-	if err != nil {
-		log.Print("Can't decode login and password")
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	err := binding.Decode(request, &auth)
+	if err != nil || auth == nil {
+		logger.Ctx(request.Context()).Warnw("can't decode login and password", "error", err)
+		respondDecodeError(writer, request, err)
 		return
 	}
-	log.Print("Login: ", auth.Login, "Password: ", auth.Password)
 
 	token, err := s.customersSvc.TokenForCustomer(request.Context(), auth.Login, auth.Password)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if errors.Is(err, customers.ErrNoSuchUser) || errors.Is(err, customers.ErrInvalidPassword) {
+		s.metrics.FailedLogins.Inc()
+		logger.Ctx(request.Context()).Warnw("failed login", "login", auth.Login)
+	}
+	if errors.Is(err, customers.ErrBlocked) {
+		logger.Ctx(request.Context()).Warnw("blocked customer login", "login", auth.Login)
+		respondFail(writer, request, http.StatusForbidden, "blocked")
 		return
 	}
-	tok.Token = token
-	data, err := json.Marshal(tok)
-	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if errors.Is(err, customers.ErrNotVerified) {
+		respondFail(writer, request, http.StatusForbidden, "not verified")
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
 	if err != nil {
+		logger.Ctx(request.Context()).Error(err)
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	s.metrics.TokensIssued.Inc()
+	tok.Token = token
+	respond(writer, request, http.StatusOK, tok)
 }
 
 func (s *Server) handleValidateToken(writer http.ResponseWriter, request *http.Request) {
 	var fail ResponceFail
 	var ok ResponceOk
 	var token Token
-	var data []byte
 	code := 200
 
-	err := json.NewDecoder(request.Body).Decode(&token)
+	err := binding.Decode(request, &token)
 	if err != nil {
-		log.Print("Can't decode token")
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		logger.Ctx(request.Context()).Warnw("can't decode token", "error", err)
+		respondDecodeError(writer, request, err)
 		return
 	}
 
@@ -157,110 +431,65 @@ func (s *Server) handleValidateToken(writer http.ResponseWriter, request *http.R
 		fail.Status = "fail"
 		fail.Reason = "expired"
 	} else if er == nil {
-		log.Print(id)
+		logger.Ctx(request.Context()).Debugw("token is valid", "customer_id", id)
 		ok.Status = "ok"
 		ok.CustomerID = id
 	} else {
-		log.Print("err", er)
+		logger.Ctx(request.Context()).Error(er)
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
 	if code != 200 {
-		writer.WriteHeader(code)
-
-		data, err = json.Marshal(fail)
-		if err != nil {
-			log.Print(err)
-		}
-	} else {
-		data, err = json.Marshal(ok)
-		if err != nil {
-			log.Print(err)
-		}
-	}
-	_, err = writer.Write(data)
-	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		respond(writer, request, code, fail)
 		return
 	}
-
-	return
+	respond(writer, request, code, ok)
 }
 
 func (s *Server) SaveCustomers(writer http.ResponseWriter, request *http.Request) {
 	var item *customers.Customer
-	err := json.NewDecoder(request.Body).Decode(&item)
-	log.Print(item)
+	err := binding.Decode(request, &item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		respondDecodeError(writer, request, err)
 		return
 	}
 
 	customer, err := s.customersSvc.SaveCustomer(request.Context(), item)
 	if err != nil {
-		log.Print(err)
-		return
-	}
-	log.Print(customer)
-
-	data, err := json.Marshal(customer)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		logger.Ctx(request.Context()).Error(err)
 		return
 	}
+	logger.Ctx(request.Context()).Debugw("customer saved", "customer_id", customer.ID)
 
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
-	http.Error(writer, http.StatusText(http.StatusOK), http.StatusOK)
-	return
+	respond(writer, request, http.StatusOK, customer)
 }
 
-// handleGetAllCustomers берет всю инфу о покупателе..
+// handleGetAllCustomers - список покупателей с фильтрами q, active, created_from и created_to.
 func (s *Server) handleGetAllCustomers(writer http.ResponseWriter, request *http.Request) {
-	all, err := s.customersSvc.All(request.Context())
+	filter, err := customersFilter(request)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
+		logger.Ctx(request.Context()).Warn(err)
+		respondFail(writer, request, http.StatusBadRequest, err.Error())
 		return
 	}
-	data, err := json.Marshal(all)
+	all, err := s.customersSvc.List(request.Context(), filter)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
 		return
 	}
-	log.Print("server's working perfect")
-	_, err = writer.Write([]byte(data))
-	if err != nil {
-		log.Print("Error!: Can't write anything on data.")
-	}
+	respond(writer, request, http.StatusOK, all)
 }
 
 // handleGetAllActiveCustomers - вся инфа об активных покупателей.
 func (s *Server) handleGetAllActiveCustomers(writer http.ResponseWriter, request *http.Request) {
 	allActive, err := s.customersSvc.AllActive(request.Context())
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(request.Context()).Error(err)
 		http.Error(writer, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
 		return
 	}
-	data, err := json.Marshal(allActive)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	log.Print("server's working perfect")
-	_, err = writer.Write([]byte(data))
-	if err != nil {
-		log.Print("Error!: Can't write anything on data.")
-	}
+	respond(writer, request, http.StatusOK, allActive)
 	// //var items []*customers.Customer
 	// // чтение данных из файла json
 	// items, err := s.customersSvc.AllActive(request.Context())
@@ -297,30 +526,19 @@ func (s *Server) handleGetCustomersByID(writer http.ResponseWriter, request *htt
 
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(request.Context()).Warn(err)
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
 	item, err := s.customersSvc.ByID(request.Context(), id)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(request.Context()).Error(err)
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	data, err := json.Marshal(item)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	log.Print("server's working perfect")
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	respond(writer, request, http.StatusOK, item)
 
 	// чтение данных из файла json
 	/*item, err = s.customersSvc.ByID(request.Context(), item.ID)
@@ -351,30 +569,18 @@ func (s *Server) handleGetCustomersByID(writer http.ResponseWriter, request *htt
 // handleSaveBanner - создаёт или обновляет покупателей .
 func (s *Server) handleSaveCustomers(writer http.ResponseWriter, request *http.Request) {
 	var item *customers.Customer
-	err := json.NewDecoder(request.Body).Decode(&item)
+	err := binding.Decode(request, &item)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		respondDecodeError(writer, request, err)
 		return
 	}
 	customersRes, err := s.customersSvc.Save(request.Context(), item)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(request.Context()).Error(err)
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
-	data, err := json.Marshal(customersRes)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-	log.Print("server's working perfect")
-	writer.Header().Set("Content-Type", "application/json")
-	_, err = writer.Write(data)
-	if err != nil {
-		log.Print(err)
-	}
+	respond(writer, request, http.StatusOK, customersRes)
 	//item, err = s.customersSvc.Save(request.Context(), item)
 
 }
@@ -395,8 +601,11 @@ func (s *Server) handleSaveCustomer(writer http.ResponseWriter, request *http.Re
 
 // handleremoveByID - удаляет покупателя по идентификатору.
 func (s *Server) handleRemoveByID(writer http.ResponseWriter, request *http.Request) {
+	manager, ok := requestManager(writer, request)
+	if !ok {
+		return
+	}
 	idParam, ok := mux.Vars(request)["id"]
-
 	if !ok {
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
@@ -404,15 +613,14 @@ func (s *Server) handleRemoveByID(writer http.ResponseWriter, request *http.Requ
 
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(request.Context()).Warn(err)
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	err = s.customersSvc.RemoveByID(request.Context(), id)
+	err = s.customersSvc.RemoveByID(request.Context(), id, "manager:"+manager.Login)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		respondCustomerChangeError(writer, request, err)
 		return
 	}
 
@@ -429,8 +637,27 @@ func (s *Server) handleRemoveByID(writer http.ResponseWriter, request *http.Requ
 		}*/
 }
 
+// respondCustomerChangeError отвечает на ошибку блокировки, разблокировки или удаления покупателя.
+func respondCustomerChangeError(writer http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, customers.ErrNotFound):
+		logger.Ctx(request.Context()).Warn(err)
+		respondFail(writer, request, http.StatusNotFound, "not found")
+	case errors.Is(err, customers.ErrReferenced):
+		logger.Ctx(request.Context()).Warn(err)
+		respondFail(writer, request, http.StatusConflict, err.Error())
+	default:
+		logger.Ctx(request.Context()).Error(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
 // handleBlockById - выставляет статус active в false.
 func (s *Server) handleBlockByID(writer http.ResponseWriter, request *http.Request) {
+	manager, ok := requestManager(writer, request)
+	if !ok {
+		return
+	}
 	idParam, ok := mux.Vars(request)["id"]
 	if !ok {
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
@@ -439,22 +666,26 @@ func (s *Server) handleBlockByID(writer http.ResponseWriter, request *http.Reque
 
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(request.Context()).Warn(err)
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	err = s.customersSvc.BlockByID(request.Context(), id)
+	err = s.customersSvc.BlockByID(request.Context(), id, "manager:"+manager.Login)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		respondCustomerChangeError(writer, request, err)
 		return
 	}
+	s.metrics.CustomersBlocked.Inc()
 	// err = s.customersSvc.BlockByID(request.Context(), item.ID)
 }
 
 // handleUnBlockById - выставляет статус active в true.
 func (s *Server) handleUnBlockByID(writer http.ResponseWriter, request *http.Request) {
+	manager, ok := requestManager(writer, request)
+	if !ok {
+		return
+	}
 	idParam, ok := mux.Vars(request)["id"]
 	if !ok {
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
@@ -463,15 +694,14 @@ func (s *Server) handleUnBlockByID(writer http.ResponseWriter, request *http.Req
 
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(request.Context()).Warn(err)
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	err = s.customersSvc.UnBlockByID(request.Context(), id)
+	err = s.customersSvc.UnBlockByID(request.Context(), id, "manager:"+manager.Login)
 	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		respondCustomerChangeError(writer, request, err)
 		return
 	}
 	// err = s.customersSvc.UnBlockByID(request.Context(), item.ID)
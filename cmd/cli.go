@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/az1zcheckit/crud/pkg/config"
+	"go.uber.org/dig"
+)
+
+// Коды выхода.
+const (
+	exitOK = 0
+	// exitFailure - команда не выполнена.
+	exitFailure = 1
+	// exitUsage - неверные флаги, аргументы или настройки.
+	exitUsage = 2
+	// exitPartial - команда выполнена не для всех объектов:
+	// в файле импорта есть ошибочные строки, часть покупателей не найдена.
+	exitPartial = 3
+)
+
+// command - подкоманда CLI.
+type command struct {
+	name string
+	// args - позиционные аргументы для справки.
+	args    string
+	summary string
+	// flags добавляет флаги команды и возвращает функцию, которая её выполняет.
+	flags func(fs *flag.FlagSet) runner
+}
+
+// runner выполняет команду.
+type runner func(env *env) (*result, error)
+
+// env - окружение команды: настройки, общий контейнер зависимостей,
+// аргументы после флагов и стандартные потоки.
+type env struct {
+	cfg       *config.Config
+	container *dig.Container
+	args      []string
+	stdin     io.Reader
+	stdout    io.Writer
+}
+
+// result - итог команды: value печатается с -json, text - без него.
+type result struct {
+	value interface{}
+	text  string
+	code  int
+}
+
+// usageError - ошибка в аргументах команды, завершает её с exitUsage.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// commands возвращает подкоманды в порядке, в котором они показываются в справке.
+func commands() []*command {
+	return []*command{
+		{name: "serve", summary: "Start the HTTP server (default command).", flags: noFlags(runServe)},
+		{name: "migrate", summary: "Apply pending database migrations.", flags: migrateFlags},
+		{name: "create-manager", summary: "Add a manager; the password is read from stdin unless -password is set.", flags: createManagerFlags},
+		{name: "reset-password", summary: "Set a new manager password and close the manager's sessions.", flags: resetPasswordFlags},
+		{name: "block-customer", args: "ID...", summary: "Block (or with -unblock unblock) customers and record it in the audit log.", flags: blockCustomerFlags},
+		{name: "purge-tokens", summary: "Delete expired customer tokens and manager sessions.", flags: noFlags(runPurgeTokens)},
+		{name: "import", args: "FILE.csv|-", summary: "Import customers from CSV (name,phone[,active][,created]).", flags: importFlags},
+		{name: "export", summary: "Export customers as CSV, JSON Lines or XLSX.", flags: exportFlags},
+		{name: "seed", summary: "Fill the database with demo customers and optionally a demo manager.", flags: seedFlags},
+	}
+}
+
+// noFlags - flags для команды без собственных флагов.
+func noFlags(run runner) func(fs *flag.FlagSet) runner {
+	return func(fs *flag.FlagSet) runner {
+		return run
+	}
+}
+
+// run разбирает подкоманду и её флаги, выполняет её и возвращает код выхода.
+// Флаги настроек сервера (--config, --dsn, ...) и переменные CRUD_* действуют для всех команд.
+func run(program string, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage(stdout, program)
+		return exitOK
+	}
+	var cmd *command
+	for _, item := range commands() {
+		if item.name == name {
+			cmd = item
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+		printUsage(stderr, program)
+		return exitUsage
+	}
+
+	var jsonOutput bool
+	var execute runner
+	cfg, rest, err := config.LoadCommand(program+" "+name, args, os.Getenv, stderr, func(fs *flag.FlagSet) {
+		fs.BoolVar(&jsonOutput, "json", false, "print the result as JSON")
+		execute = cmd.flags(fs)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", program, name, cmd.args, cmd.summary)
+			fs.PrintDefaults()
+		}
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return fail(stdout, stderr, jsonOutput, name, usagef("%v", err))
+	}
+
+	if cfg.PrintConfig {
+		err = cfg.Redacted().WriteYAML(stdout)
+		if err != nil {
+			return fail(stdout, stderr, jsonOutput, name, err)
+		}
+		return exitOK
+	}
+
+	container, err := newContainer(cfg)
+	if err != nil {
+		return fail(stdout, stderr, jsonOutput, name, err)
+	}
+	defer stopContainer(container, cfg)
+
+	res, err := execute(&env{cfg: cfg, container: container, args: rest, stdin: stdin, stdout: stdout})
+	if err != nil {
+		return fail(stdout, stderr, jsonOutput, name, err)
+	}
+	if res == nil {
+		return exitOK
+	}
+	if jsonOutput {
+		err = writeJSON(stdout, res.value)
+	} else if res.text != "" {
+		_, err = fmt.Fprintln(stdout, res.text)
+	}
+	if err != nil {
+		return fail(stdout, stderr, jsonOutput, name, err)
+	}
+	return res.code
+}
+
+// fail сообщает об ошибке команды и возвращает код выхода.
+func fail(stdout io.Writer, stderr io.Writer, jsonOutput bool, name string, err error) int {
+	// цепочка сборки зависимостей из dig для пользователя не нужна
+	err = dig.RootCause(err)
+	code := exitFailure
+	var usage *usageError
+	if errors.As(err, &usage) {
+		code = exitUsage
+	}
+	if jsonOutput {
+		_ = writeJSON(stdout, map[string]string{"error": err.Error()})
+	} else {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+	}
+	return code
+}
+
+// interruptible возвращает контекст команды, который отменяется по SIGINT/SIGTERM.
+func interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func printUsage(w io.Writer, program string) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags] [args]\n\nCommands:\n", program)
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands() {
+		fmt.Fprintf(table, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	_ = table.Flush()
+	fmt.Fprintf(w, "\nRun '%s COMMAND -h' for the command's flags. "+
+		"All commands accept the server config flags and CRUD_* environment variables, and -json for machine-readable output.\n", program)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/az1zcheckit/crud/pkg/customers"
+	"github.com/az1zcheckit/crud/pkg/security"
+	"go.uber.org/zap"
+)
+
+// blockCustomerFlags - подкоманда block-customer.
+func blockCustomerFlags(fs *flag.FlagSet) runner {
+	unblock := fs.Bool("unblock", false, "unblock instead of block")
+	return func(env *env) (*result, error) {
+		if len(env.args) == 0 {
+			return nil, usagef("at least one customer id is required")
+		}
+		ids := make([]int64, 0, len(env.args))
+		for _, arg := range env.args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil || id <= 0 {
+				return nil, usagef("invalid customer id %q", arg)
+			}
+			ids = append(ids, id)
+		}
+		action := customers.BulkBlock
+		if *unblock {
+			action = customers.BulkUnblock
+		}
+		ctx, cancel := interruptible()
+		defer cancel()
+
+		var report *customers.BulkReport
+		err := env.container.Invoke(func(customersSvc *customers.Service) (err error) {
+			report, err = customersSvc.Bulk(ctx, action, ids, nil, cliActor())
+			return err
+		})
+		if errors.Is(err, customers.ErrInvalidBulk) || errors.Is(err, customers.ErrBulkTooLarge) {
+			return nil, usagef("%v", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		res := &result{
+			value: report,
+			text: fmt.Sprintf("%s: %d changed, %d unchanged, %d not found",
+				action, report.Changed, report.Unchanged, report.NotFound),
+		}
+		if report.NotFound > 0 || report.Failed > 0 {
+			res.code = exitPartial
+		}
+		return res, nil
+	}
+}
+
+// cliActor - автор изменений из CLI для журнала customers_audit.
+func cliActor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return "cli:" + user
+	}
+	return "cli"
+}
+
+// importFlags - подкоманда import: загружает покупателей из CSV-файла (или stdin при "-").
+// Если в файле есть ошибочные строки, ничего не записывается, а код выхода - exitPartial.
+func importFlags(fs *flag.FlagSet) runner {
+	dryRun := fs.Bool("dry-run", false, "only validate the file and count inserts and updates")
+	batchSize := fs.Int("batch-size", customers.DefaultImportBatch, "rows per COPY batch")
+	return func(env *env) (*result, error) {
+		if len(env.args) != 1 {
+			return nil, usagef("exactly one file is required, use - for stdin")
+		}
+		if *batchSize <= 0 {
+			return nil, usagef("-batch-size must be positive")
+		}
+
+		reader := env.stdin
+		if path := env.args[0]; path != "-" {
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, usagef("%v", err)
+			}
+			defer file.Close()
+			reader = file
+		}
+		ctx, cancel := interruptible()
+		defer cancel()
+
+		var report *customers.ImportReport
+		err := env.container.Invoke(func(customersSvc *customers.Service) (err error) {
+			report, err = customersSvc.Import(ctx, reader, customers.ImportOptions{
+				DryRun:    *dryRun,
+				BatchSize: *batchSize,
+				Actor:     cliActor(),
+			})
+			return err
+		})
+		if errors.Is(err, customers.ErrInvalidImport) {
+			return nil, usagef("%v", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return importResult(report), nil
+	}
+}
+
+// importResult - результат команды по отчёту импорта; ошибки строк печатаются по одной.
+func importResult(report *customers.ImportReport) *result {
+	res := &result{value: report}
+	var text bytes.Buffer
+	for _, item := range report.Errors {
+		if item.Column != "" {
+			fmt.Fprintf(&text, "line %d, %s: %s\n", item.Line, item.Column, item.Message)
+		} else {
+			fmt.Fprintf(&text, "line %d: %s\n", item.Line, item.Message)
+		}
+	}
+	switch {
+	case len(report.Errors) > 0:
+		fmt.Fprintf(&text, "%d of %d rows are invalid, nothing imported", len(report.Errors), report.Rows)
+		res.code = exitPartial
+	case report.DryRun:
+		fmt.Fprintf(&text, "dry run: %d rows would insert %d and update %d customers", report.Rows, report.Inserted, report.Updated)
+	default:
+		fmt.Fprintf(&text, "%d rows: %d customers inserted, %d updated", report.Rows, report.Inserted, report.Updated)
+	}
+	res.text = text.String()
+	return res
+}
+
+// exportFlags - подкоманда export. Без -o выгрузка пишется в stdout,
+// а итог - только в журнал, чтобы не смешиваться с данными.
+func exportFlags(fs *flag.FlagSet) runner {
+	format := fs.String("format", customers.FormatCSV, "csv, jsonl or xlsx")
+	query := fs.String("q", "", "name or phone substring")
+	active := fs.String("active", "", "true for active, false for blocked customers")
+	createdFrom := fs.String("created-from", "", "created on or after the date, YYYY-MM-DD")
+	createdTo := fs.String("created-to", "", "created on or before the date, YYYY-MM-DD")
+	output := fs.String("o", "-", "output file, - for stdout")
+	return func(env *env) (*result, error) {
+		if len(env.args) > 0 {
+			return nil, usagef("unexpected arguments %v", env.args)
+		}
+		if _, ok := customers.ExportFormats[*format]; !ok {
+			return nil, usagef("unknown format %q", *format)
+		}
+		filter, err := customers.ParseFilter(*query, *active, *createdFrom, *createdTo)
+		if err != nil {
+			return nil, usagef("%v", err)
+		}
+
+		writer := env.stdout
+		var file *os.File
+		if *output != "-" {
+			file, err = os.Create(*output)
+			if err != nil {
+				return nil, err
+			}
+			writer = file
+		}
+		ctx, cancel := interruptible()
+		defer cancel()
+
+		var count int64
+		err = env.container.Invoke(func(customersSvc *customers.Service) (err error) {
+			count, err = customersSvc.Export(ctx, writer, *format, filter)
+			return err
+		})
+		if file != nil {
+			if cerr := file.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				// недописанный файл не оставляем
+				_ = os.Remove(*output)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if file == nil {
+			zap.S().Infow("customers exported", "count", count, "format", *format)
+			return nil, nil
+		}
+		return &result{
+			value: map[string]interface{}{"count": count, "file": *output},
+			text:  fmt.Sprintf("exported %d customers to %s", count, *output),
+		}, nil
+	}
+}
+
+// seedResult - итог seed.
+type seedResult struct {
+	Customers *customers.ImportReport `json:"customers"`
+	Manager   *managerResult          `json:"manager,omitempty"`
+}
+
+// seedFlags - подкоманда seed: добавляет демо-покупателей (через импорт, поэтому
+// повторный запуск обновляет тех же покупателей по телефону) и, с -manager, демо-менеджера
+// со случайным паролем.
+func seedFlags(fs *flag.FlagSet) runner {
+	count := fs.Int("customers", 20, "number of demo customers")
+	manager := fs.String("manager", "", "also create a manager with this login and a random password")
+	return func(env *env) (*result, error) {
+		if len(env.args) > 0 {
+			return nil, usagef("unexpected arguments %v", env.args)
+		}
+		if *count < 0 || *count > 999999 {
+			return nil, usagef("-customers must be between 0 and 999999")
+		}
+		ctx, cancel := interruptible()
+		defer cancel()
+
+		res := &seedResult{}
+		err := env.container.Invoke(func(customersSvc *customers.Service, securitySvc *security.Service) (err error) {
+			res.Customers, err = customersSvc.Import(ctx, seedCustomers(*count), customers.ImportOptions{Actor: cliActor()})
+			if err != nil || *manager == "" {
+				return err
+			}
+
+			password, err := randomPassword()
+			if err != nil {
+				return err
+			}
+			item, err := securitySvc.CreateManager(ctx, &security.Managers{
+				Name:  "Demo manager",
+				Login: *manager,
+			}, password)
+			if err != nil {
+				return err
+			}
+			res.Manager = &managerResult{ID: item.ID, Login: item.Login, Name: item.Name, Password: password}
+			return nil
+		})
+		if errors.Is(err, security.ErrLoginExists) {
+			return nil, usagef("login %q already exists", *manager)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		text := fmt.Sprintf("customers: %d inserted, %d updated", res.Customers.Inserted, res.Customers.Updated)
+		if res.Manager != nil {
+			text += fmt.Sprintf("\nmanager: %s, password %s", res.Manager.Login, res.Manager.Password)
+		}
+		return &result{value: res, text: text}, nil
+	}
+}
+
+// seedCustomers возвращает CSV для импорта с count демо-покупателями;
+// каждый пятый заблокирован.
+func seedCustomers(count int) io.Reader {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write([]string{"name", "phone", "active"})
+	for i := 1; i <= count; i++ {
+		_ = writer.Write([]string{
+			fmt.Sprintf("Demo customer %d", i),
+			fmt.Sprintf("+992900%06d", i),
+			strconv.FormatBool(i%5 != 0),
+		})
+	}
+	writer.Flush()
+	return &buf
+}
+
+// randomPassword - случайный пароль из 16 hex-символов.
+func randomPassword() (string, error) {
+	buf := make([]byte, 8)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/az1zcheckit/crud/pkg/customers"
+	"github.com/az1zcheckit/crud/pkg/migrations"
+	"github.com/az1zcheckit/crud/pkg/security"
+)
+
+// migrateFlags - подкоманда migrate; с -status только показывает неприменённые миграции.
+func migrateFlags(fs *flag.FlagSet) runner {
+	status := fs.Bool("status", false, "only list pending migrations")
+	return func(env *env) (*result, error) {
+		if len(env.args) > 0 {
+			return nil, usagef("unexpected arguments %v", env.args)
+		}
+		ctx, cancel := interruptible()
+		defer cancel()
+
+		var items []*migrations.Migration
+		err := env.container.Invoke(func(migrationsSvc *migrations.Service) (err error) {
+			if *status {
+				items, err = migrationsSvc.Pending(ctx)
+			} else {
+				items, err = migrationsSvc.Up(ctx)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if items == nil {
+			items = make([]*migrations.Migration, 0)
+		}
+
+		verb := "applied"
+		if *status {
+			verb = "pending"
+		}
+		lines := make([]string, 0, len(items))
+		for _, item := range items {
+			lines = append(lines, fmt.Sprintf("%s %04d_%s", verb, item.Version, item.Name))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "database is up to date")
+		}
+		return &result{value: map[string]interface{}{verb: items}, text: strings.Join(lines, "\n")}, nil
+	}
+}
+
+// purgeResult - итог purge-tokens.
+type purgeResult struct {
+	CustomerTokens  int64 `json:"customerTokens"`
+	ManagerSessions int64 `json:"managerSessions"`
+}
+
+// runPurgeTokens - подкоманда purge-tokens.
+func runPurgeTokens(env *env) (*result, error) {
+	if len(env.args) > 0 {
+		return nil, usagef("unexpected arguments %v", env.args)
+	}
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	res := &purgeResult{}
+	err := env.container.Invoke(func(customersSvc *customers.Service, securitySvc *security.Service) (err error) {
+		res.CustomerTokens, err = customersSvc.PurgeTokens(ctx)
+		if err != nil {
+			return err
+		}
+		res.ManagerSessions, err = securitySvc.PurgeSessions(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result{
+		value: res,
+		text:  fmt.Sprintf("deleted %d customer tokens and %d manager sessions", res.CustomerTokens, res.ManagerSessions),
+	}, nil
+}
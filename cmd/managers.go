@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/az1zcheckit/crud/pkg/security"
+)
+
+// minPasswordLength - минимальная длина пароля менеджера.
+const minPasswordLength = 8
+
+// managerResult - менеджер в выводе команд, без пароля.
+type managerResult struct {
+	ID         int64  `json:"id"`
+	Login      string `json:"login"`
+	Name       string `json:"name"`
+	Department string `json:"department,omitempty"`
+	Password   string `json:"password,omitempty"`
+}
+
+// createManagerFlags - подкоманда create-manager.
+func createManagerFlags(fs *flag.FlagSet) runner {
+	login := fs.String("login", "", "manager login (required)")
+	name := fs.String("name", "", "manager name, defaults to the login")
+	department := fs.String("department", "", "department")
+	salary := fs.Int("salary", 0, "monthly salary")
+	plan := fs.Int("plan", 0, "monthly sales plan")
+	password := fs.String("password", "", "password; read from the first line of stdin when empty")
+	return func(env *env) (*result, error) {
+		if len(env.args) > 0 {
+			return nil, usagef("unexpected arguments %v", env.args)
+		}
+		if *login == "" {
+			return nil, usagef("-login is required")
+		}
+		if *name == "" {
+			*name = *login
+		}
+		secret, err := readPassword(env, *password)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := interruptible()
+		defer cancel()
+
+		var item *security.Managers
+		err = env.container.Invoke(func(securitySvc *security.Service) (err error) {
+			item, err = securitySvc.CreateManager(ctx, &security.Managers{
+				Name:       *name,
+				Login:      *login,
+				Salary:     *salary,
+				Plan:       *plan,
+				Department: *department,
+			}, secret)
+			return err
+		})
+		if errors.Is(err, security.ErrLoginExists) {
+			return nil, usagef("login %q already exists", *login)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &result{
+			value: &managerResult{ID: item.ID, Login: item.Login, Name: item.Name, Department: item.Department},
+			text:  fmt.Sprintf("created manager %s with id %d", item.Login, item.ID),
+		}, nil
+	}
+}
+
+// resetPasswordFlags - подкоманда reset-password.
+func resetPasswordFlags(fs *flag.FlagSet) runner {
+	login := fs.String("login", "", "manager login (required)")
+	password := fs.String("password", "", "new password; read from the first line of stdin when empty")
+	return func(env *env) (*result, error) {
+		if len(env.args) > 0 {
+			return nil, usagef("unexpected arguments %v", env.args)
+		}
+		if *login == "" {
+			return nil, usagef("-login is required")
+		}
+		secret, err := readPassword(env, *password)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := interruptible()
+		defer cancel()
+
+		err = env.container.Invoke(func(securitySvc *security.Service) error {
+			return securitySvc.SetPassword(ctx, *login, secret)
+		})
+		if errors.Is(err, security.ErrNoSuchUser) {
+			return nil, fmt.Errorf("no manager with login %q", *login)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &result{
+			value: map[string]string{"login": *login},
+			text:  "password changed for " + *login,
+		}, nil
+	}
+}
+
+// readPassword возвращает пароль из флага или первой строки stdin,
+// чтобы он не попадал в историю команд и список процессов.
+func readPassword(env *env, flagValue string) (string, error) {
+	password := flagValue
+	if password == "" {
+		line, err := bufio.NewReader(env.stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", usagef("password is required: pass -password or write it to stdin")
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if len([]rune(password)) < minPasswordLength {
+		return "", usagef("password must be at least %d characters", minPasswordLength)
+	}
+	return password, nil
+}
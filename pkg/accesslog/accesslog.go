@@ -0,0 +1,242 @@
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/realip"
+	"github.com/gorilla/mux"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// FormatCommon - Common Log Format, одна строка на запрос.
+	FormatCommon = "common"
+	// FormatJSON - JSON-объект на строку.
+	FormatJSON = "json"
+	// FormatOff - журнал запросов отключён.
+	FormatOff = "off"
+)
+
+// ErrInvalidOptions возвращается, когда настройки журнала запросов неверны.
+var ErrInvalidOptions = errors.New("invalid access log options")
+
+// Options - настройки журнала запросов.
+type Options struct {
+	// Format - common, json или off.
+	Format string
+	// File - файл журнала; пустая строка - стандартный вывод.
+	File string
+	// MaxSize - размер файла в мегабайтах, после которого он ротируется.
+	MaxSize int
+	// MaxBackups - сколько старых файлов хранить.
+	MaxBackups int
+	// MaxAge - сколько дней хранить старые файлы.
+	MaxAge int
+	// Sample - доля записываемых успешных запросов по шаблону маршрута (0..1).
+	// Ответы с кодом 400 и выше записываются всегда.
+	Sample map[string]float64
+}
+
+// Entry - запись о запросе.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	RemoteIP  string    `json:"remote_ip"`
+	Principal string    `json:"principal,omitempty"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Route     string    `json:"route,omitempty"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Duration  float64   `json:"duration_ms"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// Logger пишет журнал запросов.
+type Logger struct {
+	format   string
+	sample   map[string]float64
+	resolver *realip.Resolver
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// New создаёт журнал запросов. Адрес клиента определяется через resolver.
+func New(opts Options, resolver *realip.Resolver) (*Logger, error) {
+	switch opts.Format {
+	case FormatCommon, FormatJSON, FormatOff:
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidOptions, opts.Format)
+	}
+	for route, rate := range opts.Sample {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%w: sample rate %v for %s out of range", ErrInvalidOptions, rate, route)
+		}
+	}
+	l := &Logger{format: opts.Format, sample: opts.Sample, resolver: resolver, out: os.Stdout}
+	if opts.File != "" {
+		l.out = &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSize,
+			MaxBackups: opts.MaxBackups,
+			MaxAge:     opts.MaxAge,
+		}
+	}
+	return l, nil
+}
+
+// Close закрывает файл журнала.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if closer, ok := l.out.(io.Closer); ok && l.out != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+// SetPrincipal запоминает, от чьего имени выполняется запрос (например, manager:login),
+// чтобы это попало в журнал. Вызывается middleware и обработчиками после аутентификации.
+func SetPrincipal(ctx context.Context, principal string) {
+	if entry, ok := ctx.Value(contextKey{}).(*Entry); ok {
+		entry.Principal = principal
+	}
+}
+
+// Middleware пишет запись о каждом запросе. Оборачивает весь роутер,
+// поэтому в журнал попадают и запросы к несуществующим маршрутам.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	if l.format == FormatOff {
+		return next
+	}
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		entry := &Entry{
+			Time:      start,
+			RequestID: logger.RequestID(request.Context()),
+			RemoteIP:  l.resolver.ClientIP(request),
+			Method:    request.Method,
+			URI:       request.RequestURI,
+			Proto:     request.Proto,
+			Referer:   request.Referer(),
+			UserAgent: request.UserAgent(),
+		}
+		recorder := &recorder{ResponseWriter: writer, status: http.StatusOK}
+		ctx := context.WithValue(request.Context(), contextKey{}, entry)
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+
+		entry.Status = recorder.status
+		entry.Bytes = recorder.bytes
+		entry.Duration = float64(time.Since(start).Microseconds()) / 1000
+		if l.sampled(entry) {
+			l.write(entry)
+		}
+	})
+}
+
+// Route - middleware для mux.Router.Use: записывает шаблон маршрута,
+// который известен только после выбора маршрута роутером.
+func Route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if entry, ok := request.Context().Value(contextKey{}).(*Entry); ok {
+			if route := mux.CurrentRoute(request); route != nil {
+				entry.Route, _ = route.GetPathTemplate()
+			}
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func (l *Logger) sampled(entry *Entry) bool {
+	if entry.Status >= http.StatusBadRequest {
+		return true
+	}
+	rate, ok := l.sample[entry.Route]
+	if !ok {
+		return true
+	}
+	return rand.Float64() < rate
+}
+
+func (l *Logger) write(entry *Entry) {
+	var line []byte
+	if l.format == FormatJSON {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			logger.Ctx(context.Background()).Error(err)
+			return
+		}
+		line = append(data, '\n')
+	} else {
+		line = []byte(common(entry))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.out.Write(line)
+	if err != nil {
+		logger.Ctx(context.Background()).Error(err)
+	}
+}
+
+// common форматирует запись в Common Log Format:
+// host ident authuser [date] "request" status bytes
+func common(entry *Entry) string {
+	principal := entry.Principal
+	if principal == "" {
+		principal = "-"
+	}
+	return fmt.Sprintf("%s - %s [%s] %s %d %d\n",
+		entry.RemoteIP,
+		principal,
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(entry.Method+" "+entry.URI+" "+entry.Proto),
+		entry.Status,
+		entry.Bytes,
+	)
+}
+
+// recorder запоминает код ответа и число отправленных байт.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *recorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,287 @@
+package binding
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Поддерживаемые типы содержимого.
+const (
+	MIMEJSON      = "application/json"
+	MIMEMsgpack   = "application/msgpack"
+	MIMEXMsgpack  = "application/x-msgpack"
+	MIMEForm      = "application/x-www-form-urlencoded"
+	MIMEMultipart = "multipart/form-data"
+)
+
+// MaxMemory - сколько байт multipart-формы держится в памяти, остальное уходит во временные файлы.
+const MaxMemory = 1 << 20
+
+// dateLayout - формат даты полей формы (<input type="date">).
+const dateLayout = "2006-01-02"
+
+// ErrUnsupportedMediaType возвращается, когда тип тела запроса не поддерживается.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// ErrInvalidValue возвращается, когда значение поля формы не приводится к типу поля.
+var ErrInvalidValue = errors.New("invalid form value")
+
+// Decode читает тело запроса в v по заголовку Content-Type: JSON (и при отсутствии заголовка),
+// msgpack, application/x-www-form-urlencoded или multipart/form-data.
+// Имена полей во всех форматах берутся из тегов json, так что одни и те же структуры
+// принимаются в любом виде.
+func Decode(request *http.Request, v interface{}) error {
+	mediaType := MIMEJSON
+	if header := request.Header.Get("Content-Type"); header != "" {
+		parsed, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupportedMediaType, err)
+		}
+		mediaType = parsed
+	}
+
+	switch mediaType {
+	case MIMEJSON:
+		return json.NewDecoder(request.Body).Decode(v)
+	case MIMEMsgpack, MIMEXMsgpack:
+		decoder := msgpack.NewDecoder(request.Body)
+		decoder.SetCustomStructTag("json")
+		return decoder.Decode(v)
+	case MIMEForm:
+		err := request.ParseForm()
+		if err != nil {
+			return err
+		}
+		return decodeForm(request.PostForm, v)
+	case MIMEMultipart:
+		err := request.ParseMultipartForm(MaxMemory)
+		if err != nil {
+			return err
+		}
+		return decodeForm(request.PostForm, v)
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+}
+
+// Negotiate выбирает тип ответа по заголовку Accept с учётом q-параметров.
+// Без заголовка, для */* и неподдерживаемых типов ответ отправляется в JSON.
+func Negotiate(request *http.Request) string {
+	best, bestQ := MIMEJSON, 0.0
+	for _, part := range strings.Split(request.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if param, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+		}
+		var candidate string
+		switch mediaType {
+		case MIMEJSON, "application/*", "*/*":
+			candidate = MIMEJSON
+		case MIMEMsgpack, MIMEXMsgpack:
+			candidate = MIMEMsgpack
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = candidate, q
+		}
+	}
+	return best
+}
+
+// Marshal кодирует v в contentType, который вернул Negotiate.
+func Marshal(contentType string, v interface{}) ([]byte, error) {
+	if contentType != MIMEMsgpack {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	err := encoder.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// decodeForm заполняет структуру, на которую указывает v, значениями формы.
+// Поля без значения в форме не меняются.
+func decodeForm(form url.Values, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("binding: non-nil pointer expected, got %T", v)
+	}
+	value = value.Elem()
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("binding: can't decode form into %T", v)
+	}
+	return decodeFields(form, value)
+}
+
+func decodeFields(form url.Values, value reflect.Value) error {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			err := decodeFields(form, value.Field(i))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		values, ok := form[name]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		err := setValue(value.Field(i), values)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidValue, name, err)
+		}
+	}
+	return nil
+}
+
+// setValue приводит значения формы к типу поля: повторяющиеся значения - в срез,
+// остальные берут первое значение.
+func setValue(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		if values[0] == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		elem := reflect.New(field.Type().Elem())
+		err := setValue(elem.Elem(), values)
+		if err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			err := setValue(slice.Index(i), []string{value})
+			if err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	value := values[0]
+	if field.Type() == timeType {
+		return setTime(field, value)
+	}
+	if reflect.PtrTo(field.Type()).Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		// флажок формы без value отправляется как "on"
+		switch strings.ToLower(value) {
+		case "on", "yes":
+			field.SetBool(true)
+		case "", "off", "no":
+			field.SetBool(false)
+		default:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			field.SetBool(parsed)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			field.SetInt(0)
+			return nil
+		}
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			field.SetUint(0)
+			return nil
+		}
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			field.SetFloat(0)
+			return nil
+		}
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// setTime принимает RFC 3339 и дату без времени (в местном часовом поясе).
+func setTime(field reflect.Value, value string) error {
+	if value == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		parsed, err = time.ParseInLocation(dateLayout, value, time.Local)
+	}
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(parsed))
+	return nil
+}
@@ -0,0 +1,236 @@
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type Meta struct {
+	Note string `json:"note"`
+}
+
+type sample struct {
+	Meta
+	ID      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Active  bool      `json:"active"`
+	Rate    float64   `json:"rate"`
+	Count   *int      `json:"count"`
+	Tags    []string  `json:"tags"`
+	IDs     []int64   `json:"ids"`
+	Created time.Time `json:"created"`
+	Skip    string    `json:"-"`
+}
+
+func newRequest(t *testing.T, contentType string, body string) *http.Request {
+	t.Helper()
+	request := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	return request
+}
+
+func multipartRequest(t *testing.T, fields [][2]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range fields {
+		err := writer.WriteField(field[0], field[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRequest(t, writer.FormDataContentType(), body.String())
+}
+
+func msgpackRequest(t *testing.T, v interface{}) *http.Request {
+	t.Helper()
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRequest(t, MIMEMsgpack, string(data))
+}
+
+// TestDecode проверяет, что одна и та же структура читается из всех поддерживаемых форматов.
+func TestDecode(t *testing.T) {
+	three := 3
+	created := time.Date(2022, 1, 31, 0, 0, 0, 0, time.Local)
+	want := sample{
+		Meta: Meta{Note: "vip"},
+		ID:   7, Name: "Aziz", Active: true, Rate: 1.5, Count: &three,
+		Tags: []string{"a", "b"}, IDs: []int64{1, 2}, Created: created,
+	}
+	form := "id=7&name=Aziz&active=on&rate=1.5&count=3&tags=a&tags=b&ids=1&ids=2&created=2022-01-31&note=vip&Skip=x"
+
+	tests := []struct {
+		name    string
+		request func(t *testing.T) *http.Request
+	}{
+		{"json without content type", func(t *testing.T) *http.Request {
+			return newRequest(t, "", `{"id":7,"name":"Aziz","active":true,"rate":1.5,"count":3,"tags":["a","b"],"ids":[1,2],`+
+				`"created":"`+created.Format(time.RFC3339)+`","note":"vip"}`)
+		}},
+		{"json with charset", func(t *testing.T) *http.Request {
+			return newRequest(t, "application/json; charset=utf-8", `{"id":7,"name":"Aziz","active":true,"rate":1.5,"count":3,`+
+				`"tags":["a","b"],"ids":[1,2],"created":"`+created.Format(time.RFC3339)+`","note":"vip"}`)
+		}},
+		{"urlencoded", func(t *testing.T) *http.Request {
+			return newRequest(t, MIMEForm, form)
+		}},
+		{"multipart", func(t *testing.T) *http.Request {
+			return multipartRequest(t, [][2]string{
+				{"id", "7"}, {"name", "Aziz"}, {"active", "true"}, {"rate", "1.5"}, {"count", "3"},
+				{"tags", "a"}, {"tags", "b"}, {"ids", "1"}, {"ids", "2"}, {"created", created.Format(time.RFC3339)}, {"note", "vip"},
+			})
+		}},
+		{"msgpack", func(t *testing.T) *http.Request {
+			return msgpackRequest(t, map[string]interface{}{
+				"id": 7, "name": "Aziz", "active": true, "rate": 1.5, "count": 3,
+				"tags": []string{"a", "b"}, "ids": []int64{1, 2}, "created": created, "note": "vip",
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got sample
+			err := Decode(tt.request(t), &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Created.Equal(want.Created) {
+				t.Errorf("created = %v, want %v", got.Created, want.Created)
+			}
+			got.Created = want.Created
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// TestDecodeFormPointer проверяет разбор формы в указатель на указатель, как в обработчиках,
+// и то, что поля без значения в форме не меняются, а пустое значение обнуляет указатель.
+func TestDecodeFormPointer(t *testing.T) {
+	var item *sample
+	err := Decode(newRequest(t, MIMEForm, "name=Aziz&count=&active=off"), &item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item == nil || item.Name != "Aziz" || item.Count != nil || item.Active {
+		t.Errorf("got %+v", item)
+	}
+
+	existing := &sample{ID: 5, Name: "old", Tags: []string{"x"}}
+	err = Decode(newRequest(t, MIMEForm, "name=new"), &existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if existing.ID != 5 || existing.Name != "new" || !reflect.DeepEqual(existing.Tags, []string{"x"}) {
+		t.Errorf("got %+v", existing)
+	}
+}
+
+// TestDecodeErrors проверяет отказ для неверных значений и неподдерживаемых типов.
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        error
+	}{
+		{"bad int", MIMEForm, "id=abc", ErrInvalidValue},
+		{"int overflow", MIMEForm, "id=99999999999999999999", ErrInvalidValue},
+		{"bad bool", MIMEForm, "active=maybe", ErrInvalidValue},
+		{"bad float", MIMEForm, "rate=1,5", ErrInvalidValue},
+		{"bad slice element", MIMEForm, "ids=1&ids=x", ErrInvalidValue},
+		{"bad pointer", MIMEForm, "count=three", ErrInvalidValue},
+		{"bad date", MIMEForm, "created=31.01.2022", ErrInvalidValue},
+		{"unsupported type", "text/plain", "id=1", ErrUnsupportedMediaType},
+		{"malformed content type", "application/", "{}", ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item sample
+			err := Decode(newRequest(t, tt.contentType, tt.body), &item)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestDecodeFormTarget проверяет, что форма читается только в структуру по указателю.
+func TestDecodeFormTarget(t *testing.T) {
+	var item sample
+	if err := decodeForm(nil, item); err == nil {
+		t.Error("decoded into a non-pointer")
+	}
+	var names []string
+	if err := decodeForm(nil, &names); err == nil {
+		t.Error("decoded into a slice")
+	}
+}
+
+// TestNegotiate проверяет выбор типа ответа по Accept с учётом q.
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", MIMEJSON},
+		{"*/*", MIMEJSON},
+		{"text/html", MIMEJSON},
+		{"application/msgpack", MIMEMsgpack},
+		{"application/x-msgpack", MIMEMsgpack},
+		{"application/json, application/msgpack", MIMEJSON},
+		{"application/json;q=0.5, application/msgpack", MIMEMsgpack},
+		{"application/msgpack;q=0.1, application/json;q=0.9", MIMEJSON},
+		{"application/*;q=0.2, application/msgpack;q=0.3", MIMEMsgpack},
+		{"text/html, application/msgpack;q=0.8, */*;q=0.1", MIMEMsgpack},
+		{"application/msgpack;q=0", MIMEJSON},
+		{"application/msgpack;q=abc", MIMEJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("Accept", tt.accept)
+			if got := Negotiate(request); got != tt.want {
+				t.Errorf("Negotiate(%q) = %s, want %s", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMarshalMsgpack проверяет, что msgpack использует имена полей из тегов json.
+func TestMarshalMsgpack(t *testing.T) {
+	data, err := Marshal(MIMEMsgpack, &sample{ID: 7, Name: "Aziz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	err = msgpack.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["name"] != "Aziz" {
+		t.Errorf("name = %v, want Aziz", got["name"])
+	}
+	if _, ok := got["Skip"]; ok {
+		t.Error("field with json:\"-\" is encoded")
+	}
+}
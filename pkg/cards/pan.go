@@ -0,0 +1,72 @@
+package cards
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+)
+
+// bin - банковский идентификационный номер (первые цифры всех наших карт).
+const bin = "427638"
+
+// panLength - длина номера карты.
+const panLength = 16
+
+// generatePAN генерирует случайный номер карты с валидной контрольной цифрой по алгоритму Луна.
+func generatePAN() (string, error) {
+	digits := []byte(bin)
+	for len(digits) < panLength-1 {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits = append(digits, byte('0'+n.Int64()))
+	}
+	digits = append(digits, luhnCheckDigit(string(digits)))
+	return string(digits), nil
+}
+
+// luhnCheckDigit вычисляет контрольную цифру для номера без неё.
+func luhnCheckDigit(number string) byte {
+	sum := 0
+	double := true
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ValidPAN проверяет номер карты по алгоритму Луна.
+func ValidPAN(number string) bool {
+	if len(number) < 2 {
+		return false
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	last := len(number) - 1
+	return luhnCheckDigit(number[:last]) == number[last]
+}
+
+// maskPAN оставляет видимыми только последние четыре цифры номера.
+func maskPAN(number string) string {
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
+// hashPAN возвращает хэш номера, по которому проверяется уникальность карты.
+func hashPAN(number string) string {
+	sum := sha256.Sum256([]byte(number))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,101 @@
+package cards
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLuhnCheckDigit проверяет контрольную цифру на известных номерах.
+func TestLuhnCheckDigit(t *testing.T) {
+	tests := []struct {
+		number string
+		want   byte
+	}{
+		{"7992739871", '3'},
+		{"453201511283036", '6'},
+		{"411111111111111", '1'},
+		{"0", '0'},
+		{"", '0'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			if got := luhnCheckDigit(tt.number); got != tt.want {
+				t.Errorf("luhnCheckDigit(%q) = %c, want %c", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidPAN проверяет разбор номеров карт, включая мусор во вводе.
+func TestValidPAN(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"4532015112830366", true},
+		{"79927398713", true},
+		{"00", true},
+		{"4111111111111112", false},
+		{"4532015112830367", false},
+		{"4111 1111 1111 1111", false},
+		{"4111-1111-1111-1111", false},
+		{"411111111111111a", false},
+		{"４111111111111111", false},
+		{"0", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			if got := ValidPAN(tt.number); got != tt.want {
+				t.Errorf("ValidPAN(%q) = %v, want %v", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGeneratePAN проверяет длину, BIN и контрольную цифру сгенерированных номеров.
+func TestGeneratePAN(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		pan, err := generatePAN()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pan) != panLength || !strings.HasPrefix(pan, bin) || !ValidPAN(pan) {
+			t.Fatalf("generatePAN() = %q", pan)
+		}
+	}
+}
+
+// TestMaskPAN проверяет, что видны только последние четыре цифры.
+func TestMaskPAN(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"4276381234567890", "************7890"},
+		{"79927398713", "*******8713"},
+		{"1234", "1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			if got := maskPAN(tt.number); got != tt.want {
+				t.Errorf("maskPAN(%q) = %q, want %q", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHashPAN проверяет, что хэш стабилен, различает номера и не содержит сам номер.
+func TestHashPAN(t *testing.T) {
+	first := hashPAN("4276381234567890")
+	if first != hashPAN("4276381234567890") {
+		t.Error("hash is not stable")
+	}
+	if first == hashPAN("4276381234567891") {
+		t.Error("different numbers have the same hash")
+	}
+	if len(first) != 64 || strings.Contains(first, "4276381234567890") {
+		t.Errorf("hashPAN = %q", first)
+	}
+}
@@ -0,0 +1,424 @@
+package cards
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/money"
+	"github.com/az1zcheckit/crud/pkg/rates"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrNotFound возвращается, когда карта не найдена.
+var ErrNotFound = errors.New("card not found")
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNoSuchCustomer возвращается, когда владелец карты не найден.
+var ErrNoSuchCustomer = errors.New("no such customer")
+
+// ErrCustomerBlocked возвращается, когда владелец карты заблокирован.
+var ErrCustomerBlocked = errors.New("customer is blocked")
+
+// ErrNotVerified возвращается, когда профиль владельца ещё не прошёл проверку (KYC).
+var ErrNotVerified = errors.New("customer is not verified")
+
+// ErrCardBlocked возвращается, когда карта заблокирована.
+var ErrCardBlocked = errors.New("card is blocked")
+
+// ErrCardExpired возвращается, когда истёк срок действия карты.
+var ErrCardExpired = errors.New("card is expired")
+
+// ErrInvalidAmount возвращается, когда сумма операции не положительная.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ErrNotEnoughBalance возвращается, когда на карте недостаточно средств.
+var ErrNotEnoughBalance = errors.New("not enough balance")
+
+// ErrLimitExceeded возвращается, когда превышен дневной лимит расходов по карте.
+var ErrLimitExceeded = errors.New("daily limit exceeded")
+
+// ErrNoRate возвращается, когда для перевода между валютами нет курса.
+var ErrNoRate = errors.New("no exchange rate")
+
+// ErrNotOwner возвращается, когда покупатель переводит с чужой карты.
+var ErrNotOwner = errors.New("card belongs to another customer")
+
+// DefaultDailyLimit - дневной лимит расходов новой карты (в минимальных единицах её валюты).
+const DefaultDailyLimit = 5_000_00
+
+// validity - срок действия выпускаемой карты.
+const validity = 3 * 365 * 24 * time.Hour
+
+// Статусы карты.
+const (
+	StatusActive  = "active"
+	StatusBlocked = "blocked"
+	StatusExpired = "expired"
+)
+
+// Service описывает сервис работы с картами.
+type Service struct {
+	pool     *pgxpool.Pool
+	ratesSvc *rates.Service
+}
+
+// NewService создаёт сервис.
+func NewService(pool *pgxpool.Pool, ratesSvc *rates.Service) *Service {
+	return &Service{pool: pool, ratesSvc: ratesSvc}
+}
+
+// Card представляет информацию о банковской карте.
+// PAN хранится только в маскированном виде, полный номер (Number)
+// возвращается один раз - при выпуске карты.
+type Card struct {
+	ID         int64     `json:"id"`
+	CustomerID int64     `json:"customerId"`
+	PAN        string    `json:"pan"`
+	Number     string    `json:"number,omitempty"`
+	Currency   string    `json:"currency"`
+	Balance    int64     `json:"balance"`
+	DailyLimit int64     `json:"dailyLimit"`
+	Expire     time.Time `json:"expire"`
+	Active     bool      `json:"active"`
+	Status     string    `json:"status"`
+	Created    time.Time `json:"created"`
+
+	customerActive bool
+}
+
+// Transaction представляет перевод с карты на карту (запись журнала операций).
+// Amount списывается с карты FromCardID в валюте Currency, ToAmount зачисляется
+// на карту ToCardID в валюте ToCurrency по курсу Rate за вычетом Spread процентов.
+type Transaction struct {
+	ID         int64     `json:"id"`
+	FromCardID int64     `json:"fromCardId"`
+	ToCardID   int64     `json:"toCardId"`
+	Amount     int64     `json:"amount"`
+	Currency   string    `json:"currency"`
+	ToAmount   int64     `json:"toAmount"`
+	ToCurrency string    `json:"toCurrency"`
+	Rate       float64   `json:"rate"`
+	Spread     float64   `json:"spread"`
+	Created    time.Time `json:"created"`
+}
+
+// selectCards - общая часть запросов карт вместе с активностью владельца.
+const selectCards = `
+	SELECT c.id, c.customer_id, c.pan, c.currency, c.balance, c.daily_limit, c.expire, c.active, cu.active, c.created
+	FROM cards c JOIN customers cu ON cu.id = c.customer_id
+`
+
+// scanCard сканирует строку selectCards и вычисляет статус карты.
+func scanCard(row pgx.Row) (*Card, error) {
+	item := &Card{}
+	err := row.Scan(&item.ID, &item.CustomerID, &item.PAN, &item.Currency, &item.Balance, &item.DailyLimit,
+		&item.Expire, &item.Active, &item.customerActive, &item.Created)
+	if err != nil {
+		return nil, err
+	}
+	item.Status = status(item, time.Now())
+	return item, nil
+}
+
+// status вычисляет статус карты: карта заблокированного покупателя тоже считается заблокированной.
+func status(card *Card, now time.Time) string {
+	if !card.Active || !card.customerActive {
+		return StatusBlocked
+	}
+	if now.After(card.Expire) {
+		return StatusExpired
+	}
+	return StatusActive
+}
+
+// statusErr возвращает ошибку, соответствующую статусу карты.
+func statusErr(card *Card) error {
+	switch {
+	case !card.customerActive:
+		return ErrCustomerBlocked
+	case card.Status == StatusBlocked:
+		return ErrCardBlocked
+	case card.Status == StatusExpired:
+		return ErrCardExpired
+	}
+	return nil
+}
+
+// Issue выпускает новую карту покупателю в валюте currency (по умолчанию money.Default).
+// Заблокированному покупателю и покупателю, не прошедшему проверку, карта не выпускается.
+func (s *Service) Issue(ctx context.Context, customerID int64, currency string) (*Card, error) {
+	if currency == "" {
+		currency = money.Default
+	}
+	c, err := money.Lookup(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var active, verified bool
+	err = s.pool.QueryRow(ctx, `
+		SELECT c.active, COALESCE(p.kyc_status = 'verified', false)
+		FROM customers c LEFT JOIN customer_profiles p ON p.customer_id = c.id
+		WHERE c.id = $1
+	`, customerID).Scan(&active, &verified)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoSuchCustomer
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	if !active {
+		return nil, ErrCustomerBlocked
+	}
+	if !verified {
+		return nil, ErrNotVerified
+	}
+
+	number, err := generatePAN()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	item := &Card{
+		CustomerID:     customerID,
+		PAN:            maskPAN(number),
+		Number:         number,
+		Currency:       c.Code,
+		DailyLimit:     DefaultDailyLimit,
+		customerActive: active,
+	}
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO cards(customer_id, pan, pan_hash, currency, daily_limit, expire) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, balance, expire, active, created
+	`, customerID, item.PAN, hashPAN(number), item.Currency, item.DailyLimit, time.Now().Add(validity)).Scan(
+		&item.ID, &item.Balance, &item.Expire, &item.Active, &item.Created)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	item.Status = status(item, time.Now())
+
+	return item, nil
+}
+
+// ByID возвращает карту по идентификатору.
+func (s *Service) ByID(ctx context.Context, id int64) (*Card, error) {
+	item, err := scanCard(s.pool.QueryRow(ctx, selectCards+` WHERE c.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
+
+// ByCustomer возвращает все карты покупателя.
+func (s *Service) ByCustomer(ctx context.Context, customerID int64) ([]*Card, error) {
+	items := make([]*Card, 0)
+	rows, err := s.pool.Query(ctx, selectCards+` WHERE c.customer_id = $1 ORDER BY c.id`, customerID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanCard(rows)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// BlockByID блокирует карту.
+func (s *Service) BlockByID(ctx context.Context, id int64) error {
+	return s.setActive(ctx, id, false)
+}
+
+// UnBlockByID разблокирует карту.
+// Карту заблокированного покупателя разблокировать нельзя.
+func (s *Service) UnBlockByID(ctx context.Context, id int64) error {
+	return s.setActive(ctx, id, true)
+}
+
+func (s *Service) setActive(ctx context.Context, id int64, active bool) error {
+	var customerActive bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT cu.active FROM cards c JOIN customers cu ON cu.id = c.customer_id WHERE c.id = $1
+	`, id).Scan(&customerActive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	if active && !customerActive {
+		return ErrCustomerBlocked
+	}
+
+	_, err = s.pool.Exec(ctx, `UPDATE cards SET active = $1 WHERE id = $2`, active, id)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// SetDailyLimit устанавливает дневной лимит расходов по карте.
+func (s *Service) SetDailyLimit(ctx context.Context, id int64, limit int64) (*Card, error) {
+	if limit < 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	tag, err := s.pool.Exec(ctx, `UPDATE cards SET daily_limit = $1 WHERE id = $2`, limit, id)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrNotFound
+	}
+
+	return s.ByID(ctx, id)
+}
+
+// Transfer переводит amount (в валюте карты fromID) с карты fromID покупателя customerID
+// на карту toID. С чужой карты перевод не выполняется (ErrNotOwner). Обе карты должны быть активны, а сумма расходов по карте fromID
+// за текущие сутки вместе с amount не должна превышать её дневной лимит.
+// Если валюты карт различаются, сумма зачисления считается по курсу на момент перевода,
+// а курс и спред записываются в журнал операций.
+func (s *Service) Transfer(ctx context.Context, customerID int64, fromID int64, toID int64, amount int64) (*Transaction, error) {
+	if amount <= 0 || fromID == toID {
+		return nil, ErrInvalidAmount
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	// блокируем обе карты в порядке id, чтобы встречные переводы не попали в deadlock
+	rows, err := tx.Query(ctx, selectCards+` WHERE c.id IN ($1, $2) ORDER BY c.id FOR UPDATE OF c`, fromID, toID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	locked := make(map[int64]*Card, 2)
+	for rows.Next() {
+		item, err := scanCard(rows)
+		if err != nil {
+			rows.Close()
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		locked[item.ID] = item
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	from, to := locked[fromID], locked[toID]
+	if from == nil || to == nil {
+		return nil, ErrNotFound
+	}
+	if from.CustomerID != customerID {
+		return nil, ErrNotOwner
+	}
+	for _, card := range []*Card{from, to} {
+		if err = statusErr(card); err != nil {
+			return nil, err
+		}
+	}
+
+	var spent int64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM card_transactions
+		WHERE from_card_id = $1 AND created >= date_trunc('day', CURRENT_TIMESTAMP)
+	`, fromID).Scan(&spent)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	if spent+amount > from.DailyLimit {
+		return nil, ErrLimitExceeded
+	}
+	if from.Balance < amount {
+		return nil, ErrNotEnoughBalance
+	}
+
+	converted, rate, err := s.ratesSvc.Convert(ctx, money.Money{Amount: amount, Currency: from.Currency}, to.Currency, time.Now())
+	if errors.Is(err, rates.ErrNoRate) {
+		return nil, ErrNoRate
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	if converted.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE cards SET balance = balance - $1 WHERE id = $2`, amount, fromID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	_, err = tx.Exec(ctx, `UPDATE cards SET balance = balance + $1 WHERE id = $2`, converted.Amount, toID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	item := &Transaction{
+		FromCardID: fromID,
+		ToCardID:   toID,
+		Amount:     amount,
+		Currency:   from.Currency,
+		ToAmount:   converted.Amount,
+		ToCurrency: converted.Currency,
+		Rate:       rate.Rate,
+		Spread:     rate.Spread,
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO card_transactions(from_card_id, to_card_id, amount, currency, to_amount, to_currency, rate, spread)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created
+	`, fromID, toID, amount, item.Currency, item.ToAmount, item.ToCurrency, item.Rate, item.Spread).Scan(&item.ID, &item.Created)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
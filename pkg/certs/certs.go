@@ -0,0 +1,162 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+// ErrInvalidOptions возвращается, когда настройки TLS неверны.
+var ErrInvalidOptions = errors.New("invalid tls options")
+
+// Options - настройки TLS.
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile - сертификаты центров, которыми подписаны клиентские сертификаты (mTLS).
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType
+	// HTTP2 - предлагать h2 при согласовании протокола (ALPN).
+	HTTP2 bool
+	// ReloadInterval - как часто проверять, не изменились ли файлы.
+	ReloadInterval time.Duration
+}
+
+// Reloader хранит настройки TLS и перечитывает сертификаты при изменении файлов,
+// не перезапуская сервер: новые соединения получают новые сертификаты,
+// открытые соединения продолжают работать со старыми.
+type Reloader struct {
+	opts    Options
+	config  atomic.Value // *tls.Config
+	modTime map[string]time.Time
+}
+
+// NewReloader загружает сертификаты.
+func NewReloader(opts Options) (*Reloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, fmt.Errorf("%w: certificate and key files are required", ErrInvalidOptions)
+	}
+	if opts.ClientAuth >= tls.VerifyClientCertIfGiven && opts.ClientCAFile == "" {
+		return nil, fmt.Errorf("%w: client certificate verification requires a client CA file", ErrInvalidOptions)
+	}
+	r := &Reloader{opts: opts}
+	r.modTime = r.stat()
+	err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig возвращает настройки для http.Server: каждое соединение получает
+// актуальные сертификаты через GetConfigForClient.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: r.nextProtos(),
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &r.current().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current(), nil
+		},
+	}
+}
+
+// Run раз в ReloadInterval проверяет время изменения файлов и перечитывает их.
+// Если новые файлы не загружаются, остаются прежние сертификаты.
+func (r *Reloader) Run(ctx context.Context) {
+	if r.opts.ReloadInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.opts.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime := r.stat()
+			if equal(modTime, r.modTime) {
+				continue
+			}
+			err := r.load()
+			if err != nil {
+				logger.Ctx(ctx).Errorw("can't reload tls certificates", "error", err)
+				continue
+			}
+			r.modTime = modTime
+			logger.Ctx(ctx).Infow("reloaded tls certificates", "cert", r.opts.CertFile)
+		}
+	}
+}
+
+func (r *Reloader) current() *tls.Config {
+	return r.config.Load().(*tls.Config)
+}
+
+func (r *Reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   r.nextProtos(),
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.opts.ClientAuth,
+	}
+	if r.opts.ClientCAFile != "" {
+		data, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("%w: no certificates in %s", ErrInvalidOptions, r.opts.ClientCAFile)
+		}
+		config.ClientCAs = pool
+	}
+	r.config.Store(config)
+	return nil
+}
+
+func (r *Reloader) nextProtos() []string {
+	if r.opts.HTTP2 {
+		return []string{"h2", "http/1.1"}
+	}
+	return []string{"http/1.1"}
+}
+
+// stat возвращает время изменения файлов; недоступные файлы пропускаются.
+func (r *Reloader) stat() map[string]time.Time {
+	result := make(map[string]time.Time)
+	for _, name := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.ClientCAFile} {
+		if name == "" {
+			continue
+		}
+		if info, err := os.Stat(name); err == nil {
+			result[name] = info.ModTime()
+		}
+	}
+	return result
+}
+
+func equal(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, modTime := range a {
+		if !modTime.Equal(b[name]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,14 @@
+// Package csvsafe защищает выгрузки CSV от CSV-инъекции.
+package csvsafe
+
+import "strings"
+
+// Text возвращает произвольный текст, введённый пользователями, для ячейки CSV:
+// значение, начинающееся с =, +, -, @, табуляции или возврата каретки, табличный редактор
+// выполнил бы как формулу, поэтому к нему добавляется апостроф.
+func Text(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,281 @@
+package customers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/tracing"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrInvalidBulk возвращается для неизвестного действия, пустого actor или неверно заданных
+// покупателей: нужен либо список id, либо непустой фильтр.
+var ErrInvalidBulk = errors.New("invalid bulk request")
+
+// ErrReferenced возвращается, когда покупателя нельзя удалить: на него ссылаются
+// карты, кредиты или продажи.
+var ErrReferenced = errors.New("customer is still referenced")
+
+// ErrBulkTooLarge возвращается, когда покупателей больше MaxBulk.
+var ErrBulkTooLarge = errors.New("too many customers for one bulk request")
+
+// MaxBulk - максимум покупателей в одной массовой операции.
+const MaxBulk = 1000
+
+// Массовые действия над покупателями.
+const (
+	BulkBlock   = "block"
+	BulkUnblock = "unblock"
+	BulkDelete  = "delete"
+)
+
+// Итог операции над одним покупателем.
+const (
+	// BulkOK - покупатель изменён, в журнал добавлена запись.
+	BulkOK = "ok"
+	// BulkUnchanged - покупатель уже в нужном состоянии.
+	BulkUnchanged = "unchanged"
+	// BulkNotFound - покупателя нет.
+	BulkNotFound = "not_found"
+	// BulkFailed - покупателя нельзя удалить: на него ссылаются карты, кредиты или продажи.
+	BulkFailed = "failed"
+)
+
+// BulkReport - итог массовой операции.
+type BulkReport struct {
+	Action    string        `json:"action"`
+	Total     int           `json:"total"`
+	Changed   int           `json:"changed"`
+	Unchanged int           `json:"unchanged"`
+	NotFound  int           `json:"notFound"`
+	Failed    int           `json:"failed"`
+	Results   []*BulkResult `json:"results"`
+}
+
+// BulkResult - итог операции над одним покупателем.
+type BulkResult struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Bulk блокирует, разблокирует или удаляет покупателей ids либо, если ids пусто,
+// подходящих под filter - так же, как BlockByID, UnBlockByID и RemoveByID, но в одной транзакции.
+// Каждое изменение записывается в журнал customers_audit от имени actor, без него операция не выполняется.
+// Блокировка и удаление удаляют токены покупателя, чтобы его сессии перестали действовать;
+// при удалении после фиксации транзакции удаляются и файлы его документов.
+// Отсутствующие покупатели и те, кого нельзя удалить, не прерывают операцию,
+// а попадают в отчёт; при другой ошибке ничего не меняется.
+func (s *Service) Bulk(ctx context.Context, action string, ids []int64, filter *Filter, actor string) (*BulkReport, error) {
+	ctx, span := tracing.Start(ctx, "customers.Bulk")
+	defer span.End()
+
+	if action != BulkBlock && action != BulkUnblock && action != BulkDelete {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidBulk, action)
+	}
+	if actor == "" {
+		return nil, fmt.Errorf("%w: actor is required", ErrInvalidBulk)
+	}
+	if (len(ids) == 0) == (filter == nil) {
+		return nil, fmt.Errorf("%w: either ids or a filter is required", ErrInvalidBulk)
+	}
+	if len(ids) > MaxBulk {
+		return nil, ErrBulkTooLarge
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	if filter != nil {
+		ids, err = s.bulkIDs(ctx, tx, *filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := &BulkReport{Action: action, Results: make([]*BulkResult, 0, len(ids))}
+	var paths []string
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		status, documents, err := s.apply(ctx, tx, action, actor, id)
+		result := &BulkResult{ID: id, Status: status}
+		paths = append(paths, documents...)
+		if errors.Is(err, ErrReferenced) {
+			result.Error = err.Error()
+		} else if err != nil {
+			return nil, err
+		}
+		report.Results = append(report.Results, result)
+		switch result.Status {
+		case BulkOK:
+			report.Changed++
+		case BulkUnchanged:
+			report.Unchanged++
+		case BulkNotFound:
+			report.NotFound++
+		case BulkFailed:
+			report.Failed++
+		}
+	}
+	report.Total = len(report.Results)
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	s.removeDocuments(ctx, paths)
+	return report, nil
+}
+
+// bulkIDs возвращает id покупателей, подходящих под непустой filter.
+func (s *Service) bulkIDs(ctx context.Context, tx pgx.Tx, filter Filter) ([]int64, error) {
+	where, args := filter.where()
+	if where == "" {
+		return nil, fmt.Errorf("%w: empty filter", ErrInvalidBulk)
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT id FROM customers `+where+` ORDER BY id LIMIT `+strconv.Itoa(MaxBulk+1), args...)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	if len(ids) > MaxBulk {
+		return nil, ErrBulkTooLarge
+	}
+	return ids, nil
+}
+
+// apply выполняет action над покупателем id в транзакции tx, записывает изменение в журнал
+// от имени actor и возвращает итог (BulkOK, BulkUnchanged, BulkNotFound или BulkFailed)
+// и пути файлов документов удалённого покупателя - их удаляют после фиксации tx.
+// Через неё проходят и Bulk, и BlockByID, UnBlockByID, RemoveByID. Для BulkFailed
+// возвращается ErrReferenced с именем нарушенного ограничения, транзакция при этом не прерывается.
+func (s *Service) apply(ctx context.Context, tx pgx.Tx, action string, actor string, id int64) (string, []string, error) {
+	var active bool
+	var paths []string
+	err := tx.QueryRow(ctx, `SELECT active FROM customers WHERE id = $1 FOR UPDATE`, id).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return BulkNotFound, nil, nil
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return "", nil, ErrInternal
+	}
+
+	switch action {
+	case BulkBlock, BulkUnblock:
+		if active == (action == BulkUnblock) {
+			return BulkUnchanged, nil, nil
+		}
+		_, err = tx.Exec(ctx, `UPDATE customers SET active = $2 WHERE id = $1`, id, action == BulkUnblock)
+		if err == nil && action == BulkBlock {
+			// у заблокированного покупателя не должно остаться действующих сессий
+			_, err = tx.Exec(ctx, `DELETE FROM customers_tokens WHERE customer_id = $1`, id)
+		}
+	case BulkDelete:
+		var constraint string
+		paths, constraint, err = deleteCustomer(ctx, tx, id)
+		if err == nil && constraint != "" {
+			return BulkFailed, nil, fmt.Errorf("%w: %s", ErrReferenced, constraint)
+		}
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return "", nil, ErrInternal
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO customers_audit(customer_id, action, actor, request_id) VALUES ($1, $2, $3, $4)
+	`, id, action, actor, logger.RequestID(ctx))
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return "", nil, ErrInternal
+	}
+	return BulkOK, paths, nil
+}
+
+// deleteCustomer удаляет покупателя id вместе с его токенами в точке сохранения, чтобы
+// нарушение внешнего ключа не прерывало всю транзакцию, и возвращает пути файлов его
+// документов (записи о них удаляются каскадно). Если на покупателя ссылаются
+// карты, кредиты или продажи, он не удаляется, а возвращается имя нарушенного ограничения.
+func deleteCustomer(ctx context.Context, tx pgx.Tx, id int64) (paths []string, constraint string, err error) {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer savepoint.Rollback(ctx)
+
+	_, err = savepoint.Exec(ctx, `DELETE FROM customers_tokens WHERE customer_id = $1`, id)
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := savepoint.Query(ctx, `SELECT path FROM customer_documents WHERE customer_id = $1`, id)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		err = rows.Scan(&path)
+		if err != nil {
+			return nil, "", err
+		}
+		paths = append(paths, path)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, "", err
+	}
+
+	_, err = savepoint.Exec(ctx, `DELETE FROM customers WHERE id = $1`, id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return nil, pgErr.ConstraintName, savepoint.Rollback(ctx)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return paths, "", savepoint.Commit(ctx)
+}
+
+// removeDocuments удаляет файлы документов удалённых покупателей.
+// Ошибки только пишутся в журнал: записи о документах уже удалены.
+func (s *Service) removeDocuments(ctx context.Context, paths []string) {
+	for _, path := range paths {
+		err := s.storage.Remove(path)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package customers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/csvsafe"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/tracing"
+	"github.com/az1zcheckit/crud/pkg/xlsx"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrUnknownFormat возвращается для неподдерживаемого формата выгрузки.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Форматы выгрузки покупателей.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXLSX  = "xlsx"
+)
+
+// ExportFormats - поддерживаемые форматы выгрузки и их Content-Type.
+var ExportFormats = map[string]string{
+	FormatCSV:   "text/csv",
+	FormatJSONL: "application/jsonl",
+	FormatXLSX:  xlsx.MIME,
+}
+
+// exportFetch - строк, которые читаются из курсора за один FETCH.
+const exportFetch = 500
+
+// exportColumns - колонки выгрузки; пароль не выгружается.
+var exportColumns = []string{"id", "name", "phone", "active", "created"}
+
+// Export записывает в w покупателей, подходящих под filter, в формате format.
+// Строки читаются из курсора порциями по exportFetch в одной транзакции только для чтения,
+// поэтому память не зависит от числа покупателей, а выгрузка согласована.
+// Возвращает число выгруженных покупателей.
+func (s *Service) Export(ctx context.Context, w io.Writer, format string, filter Filter) (int64, error) {
+	ctx, span := tracing.Start(ctx, "customers.Export")
+	defer span.End()
+
+	if _, ok := ExportFormats[format]; !ok {
+		return 0, ErrUnknownFormat
+	}
+
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	// DECLARE не принимает параметры в расширенном протоколе, поэтому значения
+	// подставляет pgx на стороне клиента
+	where, args := filter.where()
+	_, err = tx.Exec(ctx, `
+		DECLARE customers_export NO SCROLL CURSOR FOR
+		SELECT id, name, phone, active, created FROM customers `+where+` ORDER BY id
+	`, append([]interface{}{pgx.QuerySimpleProtocol(true)}, args...)...)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+
+	out, err := newExportWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	for {
+		fetched, err := s.fetchExport(ctx, tx, out)
+		count += int64(fetched)
+		if err != nil {
+			return count, err
+		}
+		if fetched < exportFetch {
+			break
+		}
+	}
+	return count, out.close()
+}
+
+// fetchExport читает из курсора следующую порцию и записывает её в out.
+func (s *Service) fetchExport(ctx context.Context, tx pgx.Tx, out exportWriter) (int, error) {
+	rows, err := tx.Query(ctx, `FETCH `+strconv.Itoa(exportFetch)+` FROM customers_export`)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+	defer rows.Close()
+
+	fetched := 0
+	for rows.Next() {
+		item := &Customer{}
+		err = rows.Scan(&item.ID, &item.Name, &item.Phone, &item.Active, &item.Created)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return fetched, ErrInternal
+		}
+		// ошибка записи - обычно клиент закрыл соединение, её обрабатывает вызывающий
+		err = out.write(item)
+		if err != nil {
+			return fetched, err
+		}
+		fetched++
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return fetched, ErrInternal
+	}
+	return fetched, nil
+}
+
+// exportWriter записывает покупателей в одном из форматов выгрузки.
+type exportWriter interface {
+	write(item *Customer) error
+	close() error
+}
+
+// newExportWriter создаёт writer формата format и записывает заголовок, если он есть.
+func newExportWriter(w io.Writer, format string) (exportWriter, error) {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		return &csvExport{writer: writer}, writer.Write(exportColumns)
+	case FormatJSONL:
+		return &jsonlExport{encoder: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		writer, err := xlsx.NewWriter(w, "customers")
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxExport{writer: writer}, writer.WriteHeader(exportColumns...)
+	}
+	return nil, ErrUnknownFormat
+}
+
+type csvExport struct {
+	writer *csv.Writer
+}
+
+func (e *csvExport) write(item *Customer) error {
+	return e.writer.Write([]string{
+		strconv.FormatInt(item.ID, 10),
+		csvsafe.Text(item.Name),
+		// телефон проверяется при сохранении и формулой быть не может
+		item.Phone,
+		strconv.FormatBool(item.Active),
+		item.Created.Format(time.RFC3339),
+	})
+}
+
+func (e *csvExport) close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// exportRecord - строка JSON Lines.
+type exportRecord struct {
+	ID      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Phone   string    `json:"phone"`
+	Active  bool      `json:"active"`
+	Created time.Time `json:"created"`
+}
+
+type jsonlExport struct {
+	encoder *json.Encoder
+}
+
+func (e *jsonlExport) write(item *Customer) error {
+	return e.encoder.Encode(&exportRecord{
+		ID:      item.ID,
+		Name:    item.Name,
+		Phone:   item.Phone,
+		Active:  item.Active,
+		Created: item.Created,
+	})
+}
+
+func (e *jsonlExport) close() error {
+	return nil
+}
+
+type xlsxExport struct {
+	writer *xlsx.Writer
+}
+
+func (e *xlsxExport) write(item *Customer) error {
+	return e.writer.WriteRow(item.ID, item.Name, item.Phone, item.Active, item.Created)
+}
+
+func (e *xlsxExport) close() error {
+	return e.writer.Close()
+}
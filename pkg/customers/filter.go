@@ -0,0 +1,115 @@
+package customers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/tracing"
+)
+
+// Filter - условия отбора покупателей для списка и выгрузки; пустые поля не учитываются.
+type Filter struct {
+	// Query - подстрока имени (без учёта регистра) или телефона.
+	Query string
+	// Active - только активные (true) или только заблокированные (false).
+	Active *bool
+	// CreatedFrom и CreatedTo - границы даты создания: [CreatedFrom, CreatedTo).
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// ParseFilter собирает фильтр из строковых значений (параметров запроса или флагов):
+// active - bool, createdFrom и createdTo - даты YYYY-MM-DD включительно; пустые значения пропускаются.
+func ParseFilter(query string, active string, createdFrom string, createdTo string) (Filter, error) {
+	filter := Filter{Query: query}
+	if active != "" {
+		value, err := strconv.ParseBool(active)
+		if err != nil {
+			return filter, fmt.Errorf("invalid active %q", active)
+		}
+		filter.Active = &value
+	}
+	if createdFrom != "" {
+		from, err := time.ParseInLocation("2006-01-02", createdFrom, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_from %q", createdFrom)
+		}
+		filter.CreatedFrom = from
+	}
+	if createdTo != "" {
+		to, err := time.ParseInLocation("2006-01-02", createdTo, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_to %q", createdTo)
+		}
+		filter.CreatedTo = to.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// IsZero сообщает, что фильтр не задан.
+func (f Filter) IsZero() bool {
+	return f.Query == "" && f.Active == nil && f.CreatedFrom.IsZero() && f.CreatedTo.IsZero()
+}
+
+// where возвращает условие WHERE (или пустую строку) и его аргументы.
+func (f Filter) where() (string, []interface{}) {
+	conds := make([]string, 0, 4)
+	args := make([]interface{}, 0, 4)
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if f.Query != "" {
+		add("(name ILIKE ? OR phone LIKE ?)", "%"+likeEscaper.Replace(f.Query)+"%")
+	}
+	if f.Active != nil {
+		add("active = ?", *f.Active)
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("created >= ?", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add("created < ?", f.CreatedTo)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// List возвращает покупателей, подходящих под filter, по возрастанию id.
+func (s *Service) List(ctx context.Context, filter Filter) ([]*Customer, error) {
+	ctx, span := tracing.Start(ctx, "customers.List")
+	defer span.End()
+
+	where, args := filter.where()
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, name, phone, active, created FROM customers `+where+` ORDER BY id
+	`, args...)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	items := make([]*Customer, 0)
+	for rows.Next() {
+		item := &Customer{}
+		err = rows.Scan(&item.ID, &item.Name, &item.Phone, &item.Active, &item.Created)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
@@ -0,0 +1,280 @@
+package customers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/tracing"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrInvalidImport возвращается, когда файл нельзя разобрать целиком:
+// нет заголовка, неизвестная колонка или ошибка синтаксиса CSV, а также без Actor.
+var ErrInvalidImport = errors.New("invalid import file")
+
+const (
+	// ImportAction - действие импорта в журнале customers_audit.
+	ImportAction = "import"
+	// DefaultImportBatch - строк в одном CopyFrom, если размер пачки не задан.
+	DefaultImportBatch = 1000
+	// MaxImportSize - максимальный размер файла импорта, принимаемого по HTTP.
+	MaxImportSize = 20 << 20
+)
+
+// importColumns - колонки файла импорта; name и phone обязательны.
+var importColumns = map[string]bool{"name": true, "phone": true, "active": false, "created": false}
+
+// phonePattern - телефон после удаления пробелов, дефисов и скобок.
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{9,15}$`)
+
+// ImportOptions - параметры импорта.
+type ImportOptions struct {
+	// DryRun - только проверить файл и посчитать, сколько покупателей добавится и обновится.
+	DryRun bool
+	// BatchSize - строк в одном CopyFrom; 0 - DefaultImportBatch.
+	BatchSize int
+	// Actor - кто импортирует; каждый добавленный или обновлённый покупатель
+	// записывается в журнал customers_audit от его имени. Обязателен.
+	Actor string
+}
+
+// ImportReport - итог импорта. Если в Errors есть хотя бы одна строка,
+// в базу ничего не записывается.
+type ImportReport struct {
+	DryRun   bool           `json:"dryRun"`
+	Rows     int            `json:"rows"`
+	Valid    int            `json:"valid"`
+	Inserted int            `json:"inserted"`
+	Updated  int            `json:"updated"`
+	Errors   []*ImportError `json:"errors"`
+}
+
+// ImportError - ошибка в строке файла; Line - номер строки файла, считая заголовок.
+type ImportError struct {
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// Import загружает покупателей из CSV с заголовком (name,phone[,active][,created]).
+// Сначала проверяются все строки; при ошибках отчёт возвращается без записи в базу.
+// Покупатели с уже существующим телефоном обновляются, как в Save.
+// Строки копируются пачками через CopyFrom во временную таблицу и переносятся
+// в customers одним запросом в той же транзакции вместе с записями журнала.
+func (s *Service) Import(ctx context.Context, reader io.Reader, opts ImportOptions) (*ImportReport, error) {
+	ctx, span := tracing.Start(ctx, "customers.Import")
+	defer span.End()
+
+	if opts.Actor == "" {
+		return nil, fmt.Errorf("%w: actor is required", ErrInvalidImport)
+	}
+
+	items, report, err := parseImport(reader)
+	if err != nil {
+		return nil, err
+	}
+	report.DryRun = opts.DryRun
+	if len(report.Errors) > 0 || len(items) == 0 {
+		return report, nil
+	}
+
+	if opts.DryRun {
+		phones := make([]string, len(items))
+		for i, item := range items {
+			phones[i] = item.phone
+		}
+		var existing int
+		err = s.pool.QueryRow(ctx, `SELECT count(*) FROM customers WHERE phone = ANY($1)`, phones).Scan(&existing)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		report.Updated = existing
+		report.Inserted = len(items) - existing
+		return report, nil
+	}
+
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = DefaultImportBatch
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		CREATE TEMP TABLE customers_import (name text, phone text, active boolean, created timestamp) ON COMMIT DROP
+	`)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	for start := 0; start < len(items); start += batch {
+		end := start + batch
+		if end > len(items) {
+			end = len(items)
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"customers_import"}, []string{"name", "phone", "active", "created"},
+			pgx.CopyFromSlice(end-start, func(i int) ([]interface{}, error) {
+				item := items[start+i]
+				return []interface{}{item.name, item.phone, item.active, item.created}, nil
+			}))
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+	}
+
+	// xmax = 0 у только что вставленной строки, у обновлённой - id транзакции
+	err = tx.QueryRow(ctx, `
+		WITH upserted AS (
+			INSERT INTO customers(name, phone, active, created)
+			SELECT name, phone, COALESCE(active, true), COALESCE(created, CURRENT_TIMESTAMP) FROM customers_import
+			ON CONFLICT (phone) DO UPDATE SET name = excluded.name, active = excluded.active, created = excluded.created
+			RETURNING id, xmax = 0 AS inserted
+		), audit AS (
+			INSERT INTO customers_audit(customer_id, action, actor, request_id)
+			SELECT id, $1, $2, $3 FROM upserted
+		)
+		SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted) FROM upserted
+	`, ImportAction, opts.Actor, logger.RequestID(ctx)).Scan(&report.Inserted, &report.Updated)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	return report, nil
+}
+
+// importRow - проверенная строка импорта; active и created - nil, если не заданы.
+type importRow struct {
+	name    string
+	phone   string
+	active  *bool
+	created *time.Time
+}
+
+// parseImport читает и проверяет все строки файла.
+func parseImport(reader io.Reader) ([]*importRow, *ImportReport, error) {
+	records := csv.NewReader(reader)
+	records.Comment = '#'
+	records.FieldsPerRecord = -1
+	records.TrimLeadingSpace = true
+
+	header, err := records.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("%w: empty file", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := importColumns[name]; !ok {
+			return nil, nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImport, name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, nil, fmt.Errorf("%w: duplicate column %q", ErrInvalidImport, name)
+		}
+		columns[name] = i
+	}
+	for name, required := range importColumns {
+		if _, ok := columns[name]; required && !ok {
+			return nil, nil, fmt.Errorf("%w: missing column %q", ErrInvalidImport, name)
+		}
+	}
+
+	report := &ImportReport{Errors: make([]*ImportError, 0)}
+	items := make([]*importRow, 0)
+	phones := make(map[string]int)
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		line, _ := records.FieldPos(0)
+		report.Rows++
+
+		item, rowErr := parseImportRow(record, columns)
+		if rowErr == nil {
+			if first, ok := phones[item.phone]; ok {
+				rowErr = &ImportError{Column: "phone", Message: "phone repeats line " + strconv.Itoa(first)}
+			} else {
+				phones[item.phone] = line
+			}
+		}
+		if rowErr != nil {
+			rowErr.Line = line
+			report.Errors = append(report.Errors, rowErr)
+			continue
+		}
+		items = append(items, item)
+	}
+	report.Valid = len(items)
+	return items, report, nil
+}
+
+// parseImportRow проверяет одну строку; возвращает первую найденную ошибку.
+func parseImportRow(record []string, columns map[string]int) (*importRow, *ImportError) {
+	if len(record) != len(columns) {
+		return nil, &ImportError{Message: fmt.Sprintf("expected %d fields, got %d", len(columns), len(record))}
+	}
+	field := func(name string) (string, bool) {
+		i, ok := columns[name]
+		if !ok {
+			return "", false
+		}
+		return strings.TrimSpace(record[i]), true
+	}
+
+	item := &importRow{}
+	item.name, _ = field("name")
+	if item.name == "" {
+		return nil, &ImportError{Column: "name", Message: "name is required"}
+	}
+	phone, _ := field("phone")
+	item.phone = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(phone)
+	if !phonePattern.MatchString(item.phone) {
+		return nil, &ImportError{Column: "phone", Message: fmt.Sprintf("invalid phone %q", phone)}
+	}
+	if value, ok := field("active"); ok && value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, &ImportError{Column: "active", Message: fmt.Sprintf("invalid boolean %q", value)}
+		}
+		item.active = &active
+	}
+	if value, ok := field("created"); ok && value != "" {
+		// RFC 3339 - формат выгрузки, чтобы выгруженный файл можно было загрузить обратно
+		created, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			created, err = time.Parse(time.RFC3339, value)
+		}
+		if err != nil {
+			return nil, &ImportError{Column: "created", Message: fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", value)}
+		}
+		item.created = &created
+	}
+	return item, nil
+}
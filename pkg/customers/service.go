@@ -5,10 +5,14 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
-	"log"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/az1zcheckit/crud/pkg/files"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/tracing"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"golang.org/x/crypto/bcrypt"
@@ -29,16 +33,24 @@ var ErrInvalidPassword = errors.New("Invalid password")
 // ErrExpiredToken возвращается когда чувак исчерпал свой токен
 var ErrExpiredToken = errors.New("Token is expired")
 
+// ErrNotVerified возвращается, когда профиль покупателя ещё не прошёл проверку (KYC)
+var ErrNotVerified = errors.New("customer is not verified")
+
+// ErrBlocked возвращается, когда покупатель заблокирован
+var ErrBlocked = errors.New("customer is blocked")
+
 // Service описывает сервис работы с покупателями.
 type Service struct {
-	pool  *pgxpool.Pool
-	mu    sync.RWMutex
-	items []*Customer
+	pool    *pgxpool.Pool
+	storage *files.Storage
+	mu      sync.RWMutex
+	items   []*Customer
 }
 
-// NewService создаёт сервис.
-func NewService(pool *pgxpool.Pool) *Service {
-	return &Service{pool: pool}
+// NewService создаёт сервис. В storage хранятся файлы документов покупателей,
+// которые удаляются вместе с ними.
+func NewService(pool *pgxpool.Pool, storage *files.Storage) *Service {
+	return &Service{pool: pool, storage: storage}
 }
 
 // Customer представляет информацию о покупателе.
@@ -54,15 +66,25 @@ type Customer struct {
 //	TokenForCustomer генерирует токен для пользователя.
 //	Если пользователь не найден, возвращается ErrNoSuchUser.
 //	Если пароль не верен, возвращается ErrInvalidPassword.
+//	Если покупатель заблокирован, возвращается ErrBlocked.
+//	Если профиль покупателя не подтверждён, возвращается ErrNotVerified.
 //	Если происходит другая ошибка, возвращается ErrInternal.
 func (s *Service) TokenForCustomer(
 	ctx context.Context,
 	phone string,
 	password string,
 ) (token string, err error) {
+	ctx, span := tracing.Start(ctx, "customers.TokenForCustomer")
+	defer span.End()
+
 	var hash string
 	var id int64
-	err = s.pool.QueryRow(ctx, `SELECT id, password FROM customers WHERE phone = $1`, phone).Scan(&id, &hash)
+	var active, verified bool
+	err = s.pool.QueryRow(ctx, `
+		SELECT c.id, c.password, c.active, COALESCE(p.kyc_status = 'verified', false)
+		FROM customers c LEFT JOIN customer_profiles p ON p.customer_id = c.id
+		WHERE c.phone = $1
+	`, phone).Scan(&id, &hash, &active, &verified)
 
 	if err == pgx.ErrNoRows {
 		return "", ErrInvalidPassword
@@ -71,10 +93,18 @@ func (s *Service) TokenForCustomer(
 		return "", ErrInternal
 	}
 
+	_, bcryptSpan := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
 	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	bcryptSpan.End()
 	if err != nil {
 		return "", ErrInvalidPassword
 	}
+	if !active {
+		return "", ErrBlocked
+	}
+	if !verified {
+		return "", ErrNotVerified
+	}
 
 	buffer := make([]byte, 256)
 	n, err := rand.Read(buffer)
@@ -91,16 +121,178 @@ func (s *Service) TokenForCustomer(
 	return token, nil
 }
 
+// Session - действующий токен покупателя (вход с одного устройства).
+type Session struct {
+	ID      int64     `json:"id"`
+	Expire  time.Time `json:"expire"`
+	Created time.Time `json:"created"`
+}
+
+// SessionID возвращает id сессии по её токену.
+//
+//	Если токена нет, возвращается ErrNotFound.
+func (s *Service) SessionID(ctx context.Context, token string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "customers.SessionID")
+	defer span.End()
+
+	var id int64
+	err := s.pool.QueryRow(ctx, `SELECT id FROM customers_tokens WHERE token = $1`, token).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+	return id, nil
+}
+
+// Sessions возвращает действующие сессии покупателя, новые первыми.
+func (s *Service) Sessions(ctx context.Context, customerID int64) ([]*Session, error) {
+	ctx, span := tracing.Start(ctx, "customers.Sessions")
+	defer span.End()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, expire, created FROM customers_tokens
+		WHERE customer_id = $1 AND expire > CURRENT_TIMESTAMP
+		ORDER BY created DESC
+	`, customerID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	items := make([]*Session, 0)
+	for rows.Next() {
+		item := &Session{}
+		err = rows.Scan(&item.ID, &item.Expire, &item.Created)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// RevokeSession завершает сессию id покупателя customerID.
+//
+//	Если такой сессии у покупателя нет, возвращается ErrNotFound.
+func (s *Service) RevokeSession(ctx context.Context, customerID int64, id int64) error {
+	ctx, span := tracing.Start(ctx, "customers.RevokeSession")
+	defer span.End()
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM customers_tokens WHERE customer_id = $1 AND id = $2`, customerID, id)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeToken завершает сессию с токеном token.
+func (s *Service) RevokeToken(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "customers.RevokeToken")
+	defer span.End()
+
+	_, err := s.pool.Exec(ctx, `DELETE FROM customers_tokens WHERE token = $1`, token)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// RevokeOtherSessions завершает все сессии покупателя, кроме сессии с токеном keep,
+// и возвращает их число.
+func (s *Service) RevokeOtherSessions(ctx context.Context, customerID int64, keep string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "customers.RevokeOtherSessions")
+	defer span.End()
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM customers_tokens WHERE customer_id = $1 AND token <> $2`, customerID, keep)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PurgeTokens удаляет истёкшие токены покупателей и возвращает их число.
+func (s *Service) PurgeTokens(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "customers.PurgeTokens")
+	defer span.End()
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM customers_tokens WHERE expire < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ChangePassword меняет пароль покупателя, проверив текущий.
+//
+//	Если покупатель не найден, возвращается ErrNoSuchUser.
+//	Если текущий пароль не верен, возвращается ErrInvalidPassword.
+//	Если происходит другая ошибка, возвращается ErrInternal.
+func (s *Service) ChangePassword(ctx context.Context, id int64, current string, password string) error {
+	ctx, span := tracing.Start(ctx, "customers.ChangePassword")
+	defer span.End()
+
+	var hash string
+	err := s.pool.QueryRow(ctx, `SELECT password FROM customers WHERE id = $1`, id).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoSuchUser
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+
+	_, bcryptSpan := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(current))
+	bcryptSpan.End()
+	if err != nil {
+		return ErrInvalidPassword
+	}
+
+	_, bcryptSpan = tracing.Start(ctx, "bcrypt.GenerateFromPassword")
+	updated, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	bcryptSpan.End()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	_, err = s.pool.Exec(ctx, `UPDATE customers SET password = $1 WHERE id = $2`, updated, id)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	return nil
+}
+
 // SaveCustomer сохраняет покупателя с паролем в файле JSON
 func (s *Service) SaveCustomer(ctx context.Context, item *Customer) (*Customer, error) {
+	ctx, span := tracing.Start(ctx, "customers.SaveCustomer")
+	defer span.End()
 
 	if item.ID == 0 {
+		_, bcryptSpan := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
 		hash, err := bcrypt.GenerateFromPassword([]byte(item.Password), bcrypt.DefaultCost)
+		bcryptSpan.End()
 		if err != nil {
-			log.Print(err)
+			logger.Ctx(ctx).Error(err)
 			return nil, ErrInternal
 		}
-		log.Print(hex.EncodeToString(hash))
 
 		res := &Customer{}
 		err = s.pool.QueryRow(ctx, `
@@ -109,7 +301,7 @@ func (s *Service) SaveCustomer(ctx context.Context, item *Customer) (*Customer,
 			`, item.Name, item.Phone, hash).Scan(&res.ID, &res.Name, &res.Phone, &res.Password, &res.Active, &res.Created)
 
 		if err != nil {
-			log.Print(err)
+			logger.Ctx(ctx).Error(err)
 			return nil, ErrInternal
 		}
 		return res, nil
@@ -119,6 +311,9 @@ func (s *Service) SaveCustomer(ctx context.Context, item *Customer) (*Customer,
 
 // ByID возвращает покупателя по идентификатору.
 func (s *Service) ByID(ctx context.Context, id int64) (*Customer, error) {
+	ctx, span := tracing.Start(ctx, "customers.ByID")
+	defer span.End()
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -133,7 +328,7 @@ func (s *Service) ByID(ctx context.Context, id int64) (*Customer, error) {
 	}
 
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return nil, ErrInternal
 	}
 
@@ -142,6 +337,9 @@ func (s *Service) ByID(ctx context.Context, id int64) (*Customer, error) {
 
 // All возвращает все данные покупателя.
 func (s *Service) All(ctx context.Context) ([]*Customer, error) {
+	ctx, span := tracing.Start(ctx, "customers.All")
+	defer span.End()
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -155,7 +353,7 @@ func (s *Service) All(ctx context.Context) ([]*Customer, error) {
 
 	// проверяем на ошибки
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return nil, err
 	}
 	// rows нужно закрывать
@@ -166,7 +364,7 @@ func (s *Service) All(ctx context.Context) ([]*Customer, error) {
 		item := &Customer{}
 		err = rows.Scan(&item.ID, &item.Name, &item.Phone, &item.Active, &item.Created)
 		if err != nil {
-			log.Print(err)
+			logger.Ctx(ctx).Error(err)
 			return nil, err
 		}
 		items = append(items, item)
@@ -174,7 +372,7 @@ func (s *Service) All(ctx context.Context) ([]*Customer, error) {
 	// в конце нужно проверять общую ошибку
 	err = rows.Err()
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return nil, err
 	}
 
@@ -183,6 +381,9 @@ func (s *Service) All(ctx context.Context) ([]*Customer, error) {
 
 // AllActive возвращает все данные активных покупателей.
 func (s *Service) AllActive(ctx context.Context) ([]*Customer, error) {
+	ctx, span := tracing.Start(ctx, "customers.AllActive")
+	defer span.End()
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -194,7 +395,7 @@ func (s *Service) AllActive(ctx context.Context) ([]*Customer, error) {
 	`)
 	// проверяем на ошибки
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return nil, ErrNotFound
 	}
 	// rows нужно закрывать
@@ -205,7 +406,7 @@ func (s *Service) AllActive(ctx context.Context) ([]*Customer, error) {
 		item := &Customer{}
 		err = rows.Scan(&item.ID, &item.Name, &item.Phone, &item.Active, &item.Created)
 		if err != nil {
-			log.Print(err)
+			logger.Ctx(ctx).Error(err)
 			return nil, err
 		}
 		items = append(items, item)
@@ -213,15 +414,66 @@ func (s *Service) AllActive(ctx context.Context) ([]*Customer, error) {
 	// в конце нужно проверять общую ошибку
 	err = rows.Err()
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return nil, err
 	}
 
 	return items, nil
 }
 
+// likeEscaper экранирует спецсимволы шаблона LIKE.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search возвращает страницу покупателей, у которых имя или телефон содержат query
+// (пустой query - все покупатели), и общее число найденных.
+func (s *Service) Search(ctx context.Context, query string, limit int, offset int) ([]*Customer, int64, error) {
+	ctx, span := tracing.Start(ctx, "customers.Search")
+	defer span.End()
+
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	var total int64
+	err := s.pool.QueryRow(ctx, `
+		SELECT count(*) FROM customers WHERE name ILIKE $1 OR phone LIKE $1
+	`, pattern).Scan(&total)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, 0, ErrInternal
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, name, phone, active, created FROM customers
+		WHERE name ILIKE $1 OR phone LIKE $1
+		ORDER BY id LIMIT $2 OFFSET $3
+	`, pattern, limit, offset)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, 0, ErrInternal
+	}
+	defer rows.Close()
+
+	items := make([]*Customer, 0, limit)
+	for rows.Next() {
+		item := &Customer{}
+		err = rows.Scan(&item.ID, &item.Name, &item.Phone, &item.Active, &item.Created)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, 0, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, 0, ErrInternal
+	}
+	return items, total, nil
+}
+
 // Save - создаёт/обновляет покупателя.
 func (s *Service) Save(ctx context.Context, item *Customer) (*Customer, error) {
+	ctx, span := tracing.Start(ctx, "customers.Save")
+	defer span.End()
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -233,7 +485,7 @@ func (s *Service) Save(ctx context.Context, item *Customer) (*Customer, error) {
 		`, item.Name, item.Phone).Scan(&res.ID, &res.Name, &res.Phone, &res.Active, &res.Created)
 
 		if err != nil {
-			log.Print(err)
+			logger.Ctx(ctx).Error(err)
 			return nil, ErrInternal
 		}
 
@@ -242,7 +494,7 @@ func (s *Service) Save(ctx context.Context, item *Customer) (*Customer, error) {
 
 	_, err := s.ByID(ctx, item.ID)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return nil, ErrNotFound
 	}
 	err = s.pool.QueryRow(ctx, `
@@ -250,77 +502,67 @@ func (s *Service) Save(ctx context.Context, item *Customer) (*Customer, error) {
 	`, item.Name, item.Phone, item.Active, item.Created, item.ID).Scan(&res.ID, &res.Name, &res.Phone, &res.Active, &res.Created)
 
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return nil, err
 	}
 
 	return res, nil
 }
 
-// RemoveByID удаляет пользователя по идентификатору.
-func (s *Service) RemoveByID(ctx context.Context, id int64) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// RemoveByID удаляет покупателя вместе с его токенами и файлами документов и записывает удаление в журнал
+// от имени actor. Если покупателя нет, возвращается ErrNotFound; если на него ссылаются
+// карты, кредиты или продажи - ErrReferenced.
+func (s *Service) RemoveByID(ctx context.Context, id int64, actor string) error {
+	ctx, span := tracing.Start(ctx, "customers.RemoveByID")
+	defer span.End()
 
-	_, err := s.ByID(ctx, id)
-	if err != nil {
-		log.Print(err)
-		return ErrNotFound
-	}
-	_, err = s.pool.Exec(ctx, `
-		DELETE FROM customers WHERE id = $1;
-	`, id)
+	return s.changeByID(ctx, BulkDelete, id, actor)
+}
 
-	if err != nil {
-		log.Print(err)
-		return err
-	}
+// BlockByID выставляет статус active в false, завершает сессии покупателя
+// и записывает блокировку в журнал от имени actor.
+func (s *Service) BlockByID(ctx context.Context, id int64, actor string) error {
+	ctx, span := tracing.Start(ctx, "customers.BlockByID")
+	defer span.End()
 
-	return nil
+	return s.changeByID(ctx, BulkBlock, id, actor)
 }
 
-// BlockByID выставляет статус active в false
-func (s *Service) BlockByID(ctx context.Context, id int64) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// UnBlockByID выставляет статус active в true и записывает разблокировку в журнал от имени actor.
+func (s *Service) UnBlockByID(ctx context.Context, id int64, actor string) error {
+	ctx, span := tracing.Start(ctx, "customers.UnBlockByID")
+	defer span.End()
 
-	_, err := s.ByID(ctx, id)
-	if err != nil {
-		log.Print(err)
-		return ErrNotFound
-	}
+	return s.changeByID(ctx, BulkUnblock, id, actor)
+}
 
-	_, err = s.pool.Exec(ctx, `
-	 	UPDATE customers SET active = false WHERE id = $1
-	`, id)
+// changeByID выполняет action над одним покупателем в своей транзакции так же, как Bulk.
+// Покупатель, уже находящийся в нужном состоянии, не считается ошибкой.
+func (s *Service) changeByID(ctx context.Context, action string, id int64, actor string) error {
+	if actor == "" {
+		return fmt.Errorf("%w: actor is required", ErrInvalidBulk)
+	}
 
+	tx, err := s.pool.Begin(ctx)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return ErrInternal
 	}
+	defer tx.Rollback(ctx)
 
-	return nil
-}
-
-// UnBlockByID выставляет статус active в true
-func (s *Service) UnBlockByID(ctx context.Context, id int64) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	_, err := s.ByID(ctx, id)
+	status, paths, err := s.apply(ctx, tx, action, actor, id)
 	if err != nil {
-		log.Print(err)
+		return err
+	}
+	if status == BulkNotFound {
 		return ErrNotFound
 	}
 
-	_, err = s.pool.Exec(ctx, `
-	 	UPDATE customers SET active = true WHERE id = $1
-	`, id)
-
+	err = tx.Commit(ctx)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return ErrInternal
 	}
-
+	s.removeDocuments(ctx, paths)
 	return nil
 }
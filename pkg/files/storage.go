@@ -0,0 +1,120 @@
+package files
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotFound возвращается, когда файл не найден.
+var ErrNotFound = errors.New("file not found")
+
+// ErrInvalidPath возвращается, когда путь выходит за пределы хранилища.
+var ErrInvalidPath = errors.New("invalid path")
+
+// ErrTooLarge возвращается, когда файл больше допустимого размера.
+var ErrTooLarge = errors.New("file is too large")
+
+// Storage хранит загруженные файлы в каталоге на локальном диске.
+type Storage struct {
+	dir string
+}
+
+// NewStorage создаёт хранилище в каталоге dir.
+func NewStorage(dir string) *Storage {
+	return &Storage{dir: dir}
+}
+
+// File описывает сохранённый файл. Path задаётся относительно каталога хранилища.
+type File struct {
+	Path     string `json:"-"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// Save сохраняет содержимое reader в подкаталог prefix под случайным именем
+// с расширением исходного файла name и считает его SHA-256.
+// Если maxSize больше нуля, файлы большего размера не сохраняются.
+func (s *Storage) Save(prefix string, name string, reader io.Reader, maxSize int64) (*File, error) {
+	buffer := make([]byte, 16)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(prefix, hex.EncodeToString(buffer)+strings.ToLower(filepath.Ext(name)))
+	full, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(full), 0o750)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(full, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	if maxSize > 0 {
+		reader = io.LimitReader(reader, maxSize+1)
+	}
+	size, err := io.Copy(io.MultiWriter(file, hash), reader)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && maxSize > 0 && size > maxSize {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		if rerr := os.Remove(full); rerr != nil {
+			zap.S().Error(rerr)
+		}
+		return nil, err
+	}
+
+	return &File{Path: path, Size: size, Checksum: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// Open открывает сохранённый файл для чтения.
+func (s *Storage) Open(path string) (*os.File, error) {
+	full, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(full)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// Remove удаляет сохранённый файл. Отсутствие файла ошибкой не считается.
+func (s *Storage) Remove(path string) error {
+	full, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(full)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// resolve превращает относительный путь в путь на диске, не давая выйти за пределы каталога.
+func (s *Storage) resolve(path string) (string, error) {
+	clean := filepath.Clean(path)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidPath
+	}
+	return filepath.Join(s.dir, clean), nil
+}
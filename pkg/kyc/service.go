@@ -0,0 +1,419 @@
+package kyc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/files"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrNotFound возвращается, когда профиль не найден.
+var ErrNotFound = errors.New("profile not found")
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNoSuchCustomer возвращается, когда покупатель не найден.
+var ErrNoSuchCustomer = errors.New("no such customer")
+
+// ErrNoSuchManager возвращается, когда менеджер не найден или не активен.
+var ErrNoSuchManager = errors.New("no such manager")
+
+// ErrInvalidProfile возвращается, когда профиль заполнен неверно.
+var ErrInvalidProfile = errors.New("invalid profile")
+
+// ErrDuplicateNationalID возвращается, когда номер удостоверения уже указан у другого покупателя.
+var ErrDuplicateNationalID = errors.New("national id already registered")
+
+// ErrInvalidStatus возвращается, когда действие недопустимо в текущем статусе проверки.
+var ErrInvalidStatus = errors.New("invalid kyc status")
+
+// ErrDocumentNotFound возвращается, когда документ не найден.
+var ErrDocumentNotFound = errors.New("document not found")
+
+// ErrInvalidDocument возвращается, когда тип документа или файла не поддерживается.
+var ErrInvalidDocument = errors.New("invalid document")
+
+// ErrDocumentTooLarge возвращается, когда документ больше MaxDocumentSize.
+var ErrDocumentTooLarge = errors.New("document is too large")
+
+// MaxDocumentSize - максимальный размер загружаемого документа.
+const MaxDocumentSize = 10 << 20
+
+// MinAge - минимальный возраст покупателя в годах.
+const MinAge = 18
+
+// documentsPrefix - подкаталог хранилища для документов покупателей.
+const documentsPrefix = "documents"
+
+// Статусы проверки покупателя (KYC). Любое изменение профиля возвращает его в StatusPending.
+const (
+	StatusPending  = "pending"
+	StatusVerified = "verified"
+	StatusRejected = "rejected"
+)
+
+// Виды документов.
+const (
+	DocumentPassport = "passport"
+	DocumentOther    = "other"
+)
+
+// documentTypes - допустимые типы содержимого сканов документов.
+var documentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// Service описывает сервис проверки покупателей (KYC).
+type Service struct {
+	pool    *pgxpool.Pool
+	storage *files.Storage
+}
+
+// NewService создаёт сервис.
+func NewService(pool *pgxpool.Pool, storage *files.Storage) *Service {
+	return &Service{pool: pool, storage: storage}
+}
+
+// Profile - расширенный профиль покупателя и статус его проверки.
+type Profile struct {
+	CustomerID int64     `json:"customerId"`
+	BirthDate  time.Time `json:"birthDate"`
+	NationalID string    `json:"nationalId"`
+	Address    string    `json:"address"`
+	Email      string    `json:"email"`
+	Status     string    `json:"status"`
+	ManagerID  *int64    `json:"managerId"`
+	Reason     string    `json:"reason"`
+	Updated    time.Time `json:"updated"`
+	Created    time.Time `json:"created"`
+}
+
+// Document - загруженный скан документа покупателя.
+type Document struct {
+	ID          int64     `json:"id"`
+	CustomerID  int64     `json:"customerId"`
+	Kind        string    `json:"kind"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum"`
+	Created     time.Time `json:"created"`
+
+	path string
+}
+
+// validate проверяет профиль и нормализует его поля.
+func (p *Profile) validate(now time.Time) error {
+	p.NationalID = strings.TrimSpace(p.NationalID)
+	p.Address = strings.TrimSpace(p.Address)
+	if p.NationalID == "" || p.Address == "" || p.BirthDate.IsZero() {
+		return ErrInvalidProfile
+	}
+	if p.BirthDate.AddDate(MinAge, 0, 0).After(now) {
+		return ErrInvalidProfile
+	}
+	address, err := mail.ParseAddress(p.Email)
+	if err != nil {
+		return ErrInvalidProfile
+	}
+	p.Email = address.Address
+	return nil
+}
+
+const selectProfiles = `
+	SELECT customer_id, birth_date, national_id, address, email, kyc_status, manager_id, reason, updated, created
+	FROM customer_profiles
+`
+
+func scanProfile(row pgx.Row) (*Profile, error) {
+	item := &Profile{}
+	err := row.Scan(&item.CustomerID, &item.BirthDate, &item.NationalID, &item.Address, &item.Email,
+		&item.Status, &item.ManagerID, &item.Reason, &item.Updated, &item.Created)
+	return item, err
+}
+
+// ByCustomer возвращает профиль покупателя.
+func (s *Service) ByCustomer(ctx context.Context, customerID int64) (*Profile, error) {
+	item, err := scanProfile(s.pool.QueryRow(ctx, selectProfiles+` WHERE customer_id = $1`, customerID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
+
+// Pending возвращает профили, ожидающие проверки, в порядке поступления.
+func (s *Service) Pending(ctx context.Context) ([]*Profile, error) {
+	items := make([]*Profile, 0)
+	rows, err := s.pool.Query(ctx, selectProfiles+` WHERE kyc_status = $1 ORDER BY updated`, StatusPending)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanProfile(rows)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// Save создаёт или изменяет профиль покупателя. Профиль (заново) уходит на проверку.
+func (s *Service) Save(ctx context.Context, item *Profile) (*Profile, error) {
+	err := item.validate(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	err = s.pool.QueryRow(ctx, `SELECT true FROM customers WHERE id = $1`, item.CustomerID).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoSuchCustomer
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	res, err := scanProfile(s.pool.QueryRow(ctx, `
+		INSERT INTO customer_profiles(customer_id, birth_date, national_id, address, email) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (customer_id) DO UPDATE SET birth_date = excluded.birth_date, national_id = excluded.national_id,
+			address = excluded.address, email = excluded.email,
+			kyc_status = 'pending', manager_id = NULL, reason = '', updated = now()
+		RETURNING customer_id, birth_date, national_id, address, email, kyc_status, manager_id, reason, updated, created
+	`, item.CustomerID, item.BirthDate, item.NationalID, item.Address, item.Email))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, ErrDuplicateNationalID
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return res, nil
+}
+
+// Verify подтверждает профиль менеджером managerID.
+// Для подтверждения нужен хотя бы один загруженный паспорт.
+func (s *Service) Verify(ctx context.Context, customerID int64, managerID int64) (*Profile, error) {
+	var passports int
+	err := s.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM customer_documents WHERE customer_id = $1 AND kind = $2
+	`, customerID, DocumentPassport).Scan(&passports)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	if passports == 0 {
+		return nil, ErrInvalidDocument
+	}
+
+	return s.decide(ctx, customerID, managerID, StatusVerified, "")
+}
+
+// Reject отклоняет профиль менеджером managerID с причиной reason.
+func (s *Service) Reject(ctx context.Context, customerID int64, managerID int64, reason string) (*Profile, error) {
+	return s.decide(ctx, customerID, managerID, StatusRejected, reason)
+}
+
+// decide переводит профиль из StatusPending в статус status.
+func (s *Service) decide(ctx context.Context, customerID int64, managerID int64, status string, reason string) (*Profile, error) {
+	var active bool
+	err := s.pool.QueryRow(ctx, `SELECT active FROM managers WHERE id = $1`, managerID).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !active) {
+		return nil, ErrNoSuchManager
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	item, err := scanProfile(s.pool.QueryRow(ctx, `
+		UPDATE customer_profiles SET kyc_status = $1, manager_id = $2, reason = $3, updated = now()
+		WHERE customer_id = $4 AND kyc_status = $5
+		RETURNING customer_id, birth_date, national_id, address, email, kyc_status, manager_id, reason, updated, created
+	`, status, managerID, reason, customerID, StatusPending))
+	if errors.Is(err, pgx.ErrNoRows) {
+		_, err = s.ByCustomer(ctx, customerID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidStatus
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
+
+// AddDocument сохраняет скан документа на диск вместе с его SHA-256.
+// Допускаются только JPEG, PNG и PDF; тип определяется по содержимому файла.
+// Новый документ возвращает профиль на проверку.
+func (s *Service) AddDocument(ctx context.Context, customerID int64, kind string, name string, reader io.Reader) (*Document, error) {
+	if kind == "" {
+		kind = DocumentPassport
+	}
+	if kind != DocumentPassport && kind != DocumentOther {
+		return nil, ErrInvalidDocument
+	}
+
+	_, err := s.ByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReaderSize(reader, 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	contentType := http.DetectContentType(head)
+	if !documentTypes[contentType] {
+		return nil, ErrInvalidDocument
+	}
+
+	file, err := s.storage.Save(documentsPrefix, name, buffered, MaxDocumentSize)
+	if errors.Is(err, files.ErrTooLarge) {
+		return nil, ErrDocumentTooLarge
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	item := &Document{CustomerID: customerID, Kind: kind, Name: name, ContentType: contentType,
+		Size: file.Size, Checksum: file.Checksum, path: file.Path}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, s.discard(ctx, file.Path)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO customer_documents(customer_id, kind, name, content_type, path, size, checksum)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created
+	`, customerID, kind, name, contentType, file.Path, file.Size, file.Checksum).Scan(&item.ID, &item.Created)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, s.discard(ctx, file.Path)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE customer_profiles SET kyc_status = $1, manager_id = NULL, reason = '', updated = now()
+		WHERE customer_id = $2
+	`, StatusPending, customerID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, s.discard(ctx, file.Path)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, s.discard(ctx, file.Path)
+	}
+
+	return item, nil
+}
+
+// discard удаляет файл, запись о котором не удалось сохранить, и возвращает ErrInternal.
+func (s *Service) discard(ctx context.Context, path string) error {
+	err := s.storage.Remove(path)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+	}
+	return ErrInternal
+}
+
+// Documents возвращает документы покупателя.
+func (s *Service) Documents(ctx context.Context, customerID int64) ([]*Document, error) {
+	items := make([]*Document, 0)
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, customer_id, kind, name, content_type, path, size, checksum, created
+		FROM customer_documents WHERE customer_id = $1 ORDER BY id
+	`, customerID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &Document{}
+		err = rows.Scan(&item.ID, &item.CustomerID, &item.Kind, &item.Name, &item.ContentType,
+			&item.path, &item.Size, &item.Checksum, &item.Created)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// OpenDocument открывает файл документа для чтения. Файл нужно закрыть.
+func (s *Service) OpenDocument(ctx context.Context, customerID int64, id int64) (*Document, *os.File, error) {
+	item := &Document{}
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, customer_id, kind, name, content_type, path, size, checksum, created
+		FROM customer_documents WHERE id = $1 AND customer_id = $2
+	`, id, customerID).Scan(&item.ID, &item.CustomerID, &item.Kind, &item.Name, &item.ContentType,
+		&item.path, &item.Size, &item.Checksum, &item.Created)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, nil, ErrInternal
+	}
+
+	file, err := s.storage.Open(item.path)
+	if errors.Is(err, files.ErrNotFound) {
+		return nil, nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, nil, ErrInternal
+	}
+
+	return item, file, nil
+}
@@ -0,0 +1,136 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+// Lifecycle управляет готовностью приложения, фоновыми задачами и порядком остановки.
+// При остановке сначала отменяются и дожидаются фоновые задачи (Go),
+// затем вызываются хуки OnStop в порядке, обратном регистрации:
+// то, что создано первым (например, пул соединений), закрывается последним.
+type Lifecycle struct {
+	ready int32
+
+	mu      sync.Mutex
+	hooks   []hook
+	workers map[string]*worker
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+type worker struct {
+	running int32
+}
+
+// New создаёт Lifecycle. Приложение не готово, пока не вызван SetReady(true).
+func New() *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{workers: make(map[string]*worker), ctx: ctx, cancel: cancel}
+}
+
+// SetReady выставляет готовность приложения принимать трафик.
+func (l *Lifecycle) SetReady(ready bool) {
+	value := int32(0)
+	if ready {
+		value = 1
+	}
+	atomic.StoreInt32(&l.ready, value)
+}
+
+// Ready возвращает true, если приложение готово принимать трафик.
+func (l *Lifecycle) Ready() bool {
+	return atomic.LoadInt32(&l.ready) == 1
+}
+
+// OnStop регистрирует хук, вызываемый при остановке.
+func (l *Lifecycle) OnStop(name string, stop func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook{name: name, stop: stop})
+}
+
+// Go запускает фоновую задачу run. Её контекст отменяется при остановке,
+// а Stop дожидается её завершения.
+func (l *Lifecycle) Go(name string, run func(ctx context.Context)) {
+	w := &worker{running: 1}
+	l.mu.Lock()
+	l.workers[name] = w
+	l.mu.Unlock()
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer atomic.StoreInt32(&w.running, 0)
+		run(l.ctx)
+	}()
+}
+
+// Workers возвращает состояние фоновых задач: имя -> выполняется ли задача.
+func (l *Lifecycle) Workers() map[string]bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	workers := make(map[string]bool, len(l.workers))
+	for name, w := range l.workers {
+		workers[name] = atomic.LoadInt32(&w.running) == 1
+	}
+	return workers
+}
+
+// Stop снимает готовность, останавливает фоновые задачи и вызывает хуки.
+// Все хуки вызываются, даже если ctx истёк или какой-то хук вернул ошибку;
+// ошибки объединяются.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.SetReady(false)
+	l.cancel()
+
+	var errs []string
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, "background workers: "+ctx.Err().Error())
+	}
+
+	l.mu.Lock()
+	hooks := l.hooks
+	l.hooks = nil
+	l.mu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		logger.Ctx(ctx).Infow("stopping", "hook", hooks[i].name)
+		err := hooks[i].stop(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", hooks[i].name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New("shutdown: " + joinErrors(errs))
+	}
+	return nil
+}
+
+func joinErrors(errs []string) string {
+	result := errs[0]
+	for _, err := range errs[1:] {
+		result += "; " + err
+	}
+	return result
+}
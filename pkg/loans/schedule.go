@@ -0,0 +1,118 @@
+package loans
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrInvalidTerms возвращается, когда сумма, ставка, срок или способ погашения заданы неверно.
+var ErrInvalidTerms = errors.New("invalid loan terms")
+
+// Способы погашения кредита.
+const (
+	MethodAnnuity        = "annuity"
+	MethodDifferentiated = "differentiated"
+)
+
+// MaxTerm - максимальный срок кредита в месяцах.
+const MaxTerm = 360
+
+// Payment - ежемесячный платёж графика погашения (суммы в дирамах).
+type Payment struct {
+	Number    int       `json:"number"`
+	Date      time.Time `json:"date"`
+	Payment   int64     `json:"payment"`
+	Principal int64     `json:"principal"`
+	Interest  int64     `json:"interest"`
+	Balance   int64     `json:"balance"`
+}
+
+// Schedule - график погашения кредита.
+type Schedule struct {
+	Method        string     `json:"method"`
+	Amount        int64      `json:"amount"`
+	Rate          float64    `json:"rate"`
+	Term          int        `json:"term"`
+	Payments      []*Payment `json:"payments"`
+	TotalInterest int64      `json:"totalInterest"`
+	Total         int64      `json:"total"`
+}
+
+// validTerms проверяет параметры кредита.
+func validTerms(amount int64, rate float64, term int, method string) bool {
+	if amount <= 0 || rate < 0 || term <= 0 || term > MaxTerm {
+		return false
+	}
+	return method == MethodAnnuity || method == MethodDifferentiated
+}
+
+// NewSchedule рассчитывает график погашения кредита amount под rate процентов годовых
+// на term месяцев. Первый платёж - через месяц после start.
+// Проценты начисляются на остаток по ставке rate/12 в месяц; копейки округляются,
+// а последний платёж гасит весь остаток, поэтому сумма основного долга равна amount.
+func NewSchedule(amount int64, rate float64, term int, method string, start time.Time) (*Schedule, error) {
+	if !validTerms(amount, rate, term, method) {
+		return nil, ErrInvalidTerms
+	}
+
+	monthly := rate / 12 / 100
+	annuity := int64(0)
+	if method == MethodAnnuity {
+		annuity = annuityPayment(amount, monthly, term)
+	}
+
+	schedule := &Schedule{Method: method, Amount: amount, Rate: rate, Term: term, Payments: make([]*Payment, 0, term)}
+	balance := amount
+	for i := 1; i <= term; i++ {
+		interest := int64(math.Round(float64(balance) * monthly))
+		var principal int64
+		switch {
+		case i == term:
+			principal = balance
+		case method == MethodAnnuity:
+			principal = annuity - interest
+		default:
+			principal = int64(math.Round(float64(amount) / float64(term)))
+		}
+		if principal > balance {
+			principal = balance
+		}
+		if principal < 0 {
+			principal = 0
+		}
+		balance -= principal
+
+		schedule.Payments = append(schedule.Payments, &Payment{
+			Number:    i,
+			Date:      addMonths(start, i),
+			Payment:   principal + interest,
+			Principal: principal,
+			Interest:  interest,
+			Balance:   balance,
+		})
+		schedule.TotalInterest += interest
+		schedule.Total += principal + interest
+	}
+
+	return schedule, nil
+}
+
+// annuityPayment вычисляет ежемесячный аннуитетный платёж A = P * r / (1 - (1 + r)^-n).
+func annuityPayment(amount int64, monthly float64, term int) int64 {
+	if monthly == 0 {
+		return int64(math.Round(float64(amount) / float64(term)))
+	}
+	return int64(math.Round(float64(amount) * monthly / (1 - math.Pow(1+monthly, -float64(term)))))
+}
+
+// addMonths прибавляет к дате months месяцев. Если в итоговом месяце нет такого числа
+// (например, 31-е), берётся последний день месяца.
+func addMonths(date time.Time, months int) time.Time {
+	first := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location()).AddDate(0, months, 0)
+	day := date.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, date.Location())
+}
@@ -0,0 +1,145 @@
+package loans
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestNewSchedule сверяет графики с рассчитанными вручную: последний платёж
+// гасит остаток, накопившийся из-за округления.
+func TestNewSchedule(t *testing.T) {
+	start := time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		amount        int64
+		rate          float64
+		term          int
+		method        string
+		want          []Payment
+		totalInterest int64
+	}{
+		{
+			// A = 120000 * 0.01 / (1 - 1.01^-3) = 40802.65 ≈ 40803; последний платёж на дирам меньше
+			name: "annuity", amount: 120000, rate: 12, term: 3, method: MethodAnnuity,
+			want: []Payment{
+				{Number: 1, Payment: 40803, Principal: 39603, Interest: 1200, Balance: 80397},
+				{Number: 2, Payment: 40803, Principal: 39999, Interest: 804, Balance: 40398},
+				{Number: 3, Payment: 40802, Principal: 40398, Interest: 404, Balance: 0},
+			},
+			totalInterest: 2408,
+		},
+		{
+			// основной долг по 33333, остаток 33334 - в последнем платеже
+			name: "differentiated", amount: 100000, rate: 12, term: 3, method: MethodDifferentiated,
+			want: []Payment{
+				{Number: 1, Payment: 34333, Principal: 33333, Interest: 1000, Balance: 66667},
+				{Number: 2, Payment: 34000, Principal: 33333, Interest: 667, Balance: 33334},
+				{Number: 3, Payment: 33667, Principal: 33334, Interest: 333, Balance: 0},
+			},
+			totalInterest: 2000,
+		},
+		{
+			name: "interest free annuity", amount: 100, rate: 0, term: 3, method: MethodAnnuity,
+			want: []Payment{
+				{Number: 1, Payment: 33, Principal: 33, Balance: 67},
+				{Number: 2, Payment: 33, Principal: 33, Balance: 34},
+				{Number: 3, Payment: 34, Principal: 34, Balance: 0},
+			},
+		},
+		{
+			name: "single payment", amount: 50000, rate: 24, term: 1, method: MethodDifferentiated,
+			want: []Payment{
+				{Number: 1, Payment: 51000, Principal: 50000, Interest: 1000, Balance: 0},
+			},
+			totalInterest: 1000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := NewSchedule(tt.amount, tt.rate, tt.term, tt.method, start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(schedule.Payments) != len(tt.want) {
+				t.Fatalf("%d payments, want %d", len(schedule.Payments), len(tt.want))
+			}
+			var principal int64
+			for i, payment := range schedule.Payments {
+				got := *payment
+				got.Date = time.Time{}
+				if got != tt.want[i] {
+					t.Errorf("payment %d = %+v, want %+v", i+1, got, tt.want[i])
+				}
+				principal += payment.Principal
+			}
+			if principal != tt.amount {
+				t.Errorf("principal sum = %d, want %d", principal, tt.amount)
+			}
+			if schedule.TotalInterest != tt.totalInterest {
+				t.Errorf("total interest = %d, want %d", schedule.TotalInterest, tt.totalInterest)
+			}
+			if schedule.Total != tt.amount+tt.totalInterest {
+				t.Errorf("total = %d, want %d", schedule.Total, tt.amount+tt.totalInterest)
+			}
+		})
+	}
+}
+
+// TestScheduleDates проверяет, что платёж 31-го числа переносится на последний день
+// короткого месяца, а в следующем месяце возвращается на 31-е.
+func TestScheduleDates(t *testing.T) {
+	tests := []struct {
+		name  string
+		start time.Time
+		want  []string
+	}{
+		{"end of january", time.Date(2022, 1, 31, 15, 30, 0, 0, time.UTC),
+			[]string{"2022-02-28", "2022-03-31", "2022-04-30", "2022-05-31"}},
+		{"leap year", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			[]string{"2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31"}},
+		{"across the year", time.Date(2022, 11, 30, 0, 0, 0, 0, time.UTC),
+			[]string{"2022-12-30", "2023-01-30", "2023-02-28", "2023-03-30"}},
+		{"middle of the month", time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC),
+			[]string{"2022-02-15", "2022-03-15", "2022-04-15", "2022-05-15"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := NewSchedule(100000, 10, len(tt.want), MethodAnnuity, tt.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, payment := range schedule.Payments {
+				if got := payment.Date.Format("2006-01-02"); got != tt.want[i] {
+					t.Errorf("payment %d date = %s, want %s", i+1, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestNewScheduleInvalidTerms проверяет отказ для неверных параметров.
+func TestNewScheduleInvalidTerms(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		amount int64
+		rate   float64
+		term   int
+		method string
+	}{
+		{"zero amount", 0, 10, 12, MethodAnnuity},
+		{"negative rate", 1000, -1, 12, MethodAnnuity},
+		{"zero term", 1000, 10, 0, MethodAnnuity},
+		{"term too long", 1000, 10, MaxTerm + 1, MethodAnnuity},
+		{"unknown method", 1000, 10, 12, "balloon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSchedule(tt.amount, tt.rate, tt.term, tt.method, start)
+			if !errors.Is(err, ErrInvalidTerms) {
+				t.Errorf("error = %v, want ErrInvalidTerms", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package loans
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrNotFound возвращается, когда заявка не найдена.
+var ErrNotFound = errors.New("loan not found")
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNoSuchCustomer возвращается, когда покупатель не найден.
+var ErrNoSuchCustomer = errors.New("no such customer")
+
+// ErrCustomerBlocked возвращается, когда покупатель заблокирован.
+var ErrCustomerBlocked = errors.New("customer is blocked")
+
+// ErrNoSuchManager возвращается, когда менеджер не найден или не активен.
+var ErrNoSuchManager = errors.New("no such manager")
+
+// ErrInvalidStatus возвращается, когда действие недопустимо в текущем статусе заявки.
+var ErrInvalidStatus = errors.New("invalid loan status")
+
+// Статусы заявки на кредит: черновик отправляется на рассмотрение (submitted),
+// менеджер одобряет (approved) или отклоняет (rejected) её, одобренный кредит выдаётся (disbursed).
+const (
+	StatusDraft     = "draft"
+	StatusSubmitted = "submitted"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusDisbursed = "disbursed"
+)
+
+// Service описывает сервис работы с кредитами.
+type Service struct {
+	pool *pgxpool.Pool
+}
+
+// NewService создаёт сервис.
+func NewService(pool *pgxpool.Pool) *Service {
+	return &Service{pool: pool}
+}
+
+// Loan представляет заявку на кредит покупателя.
+// Amount - в дирамах, Rate - процентов годовых, Term - в месяцах.
+type Loan struct {
+	ID         int64      `json:"id"`
+	CustomerID int64      `json:"customerId"`
+	ProductID  *int64     `json:"productId"`
+	Amount     int64      `json:"amount"`
+	Rate       float64    `json:"rate"`
+	Term       int        `json:"term"`
+	Method     string     `json:"method"`
+	Status     string     `json:"status"`
+	ManagerID  *int64     `json:"managerId"`
+	Reason     string     `json:"reason"`
+	Disbursed  *time.Time `json:"disbursed"`
+	Updated    time.Time  `json:"updated"`
+	Created    time.Time  `json:"created"`
+}
+
+// selectLoans - общая часть запросов заявок.
+const selectLoans = `
+	SELECT id, customer_id, product_id, amount, rate, term, method, status, manager_id, reason, disbursed, updated, created FROM loans
+`
+
+func scanLoan(row pgx.Row) (*Loan, error) {
+	item := &Loan{}
+	err := row.Scan(&item.ID, &item.CustomerID, &item.ProductID, &item.Amount, &item.Rate, &item.Term, &item.Method,
+		&item.Status, &item.ManagerID, &item.Reason, &item.Disbursed, &item.Updated, &item.Created)
+	return item, err
+}
+
+// checkCustomer проверяет, что покупатель существует и не заблокирован.
+func (s *Service) checkCustomer(ctx context.Context, customerID int64) error {
+	var active bool
+	err := s.pool.QueryRow(ctx, `SELECT active FROM customers WHERE id = $1`, customerID).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoSuchCustomer
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	if !active {
+		return ErrCustomerBlocked
+	}
+	return nil
+}
+
+// checkManager проверяет, что менеджер существует и активен.
+func (s *Service) checkManager(ctx context.Context, managerID int64) error {
+	var active bool
+	err := s.pool.QueryRow(ctx, `SELECT active FROM managers WHERE id = $1`, managerID).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !active) {
+		return ErrNoSuchManager
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// Save создаёт черновик заявки (при ID == 0) или изменяет черновик.
+func (s *Service) Save(ctx context.Context, item *Loan) (*Loan, error) {
+	if item.Method == "" {
+		item.Method = MethodAnnuity
+	}
+	if !validTerms(item.Amount, item.Rate, item.Term, item.Method) {
+		return nil, ErrInvalidTerms
+	}
+
+	if item.ID == 0 {
+		err := s.checkCustomer(ctx, item.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := scanLoan(s.pool.QueryRow(ctx, `
+			INSERT INTO loans(customer_id, product_id, amount, rate, term, method) VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, customer_id, product_id, amount, rate, term, method, status, manager_id, reason, disbursed, updated, created
+		`, item.CustomerID, item.ProductID, item.Amount, item.Rate, item.Term, item.Method))
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		return res, nil
+	}
+
+	current, err := s.ByID(ctx, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != StatusDraft {
+		return nil, ErrInvalidStatus
+	}
+
+	res, err := scanLoan(s.pool.QueryRow(ctx, `
+		UPDATE loans SET product_id = $1, amount = $2, rate = $3, term = $4, method = $5, updated = now()
+		WHERE id = $6 AND status = $7
+		RETURNING id, customer_id, product_id, amount, rate, term, method, status, manager_id, reason, disbursed, updated, created
+	`, item.ProductID, item.Amount, item.Rate, item.Term, item.Method, item.ID, StatusDraft))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidStatus
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return res, nil
+}
+
+// ByID возвращает заявку по идентификатору.
+func (s *Service) ByID(ctx context.Context, id int64) (*Loan, error) {
+	item, err := scanLoan(s.pool.QueryRow(ctx, selectLoans+` WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
+
+// ByCustomer возвращает заявки покупателя.
+func (s *Service) ByCustomer(ctx context.Context, customerID int64) ([]*Loan, error) {
+	items := make([]*Loan, 0)
+	rows, err := s.pool.Query(ctx, selectLoans+` WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanLoan(rows)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// transition переводит заявку из статуса from в статус to.
+// Если заявка в другом статусе, возвращается ErrInvalidStatus.
+func (s *Service) transition(ctx context.Context, id int64, from string, to string, managerID *int64, reason string) (*Loan, error) {
+	item, err := scanLoan(s.pool.QueryRow(ctx, `
+		UPDATE loans SET status = $1,
+			manager_id = COALESCE($2, manager_id),
+			reason = $3,
+			disbursed = CASE WHEN $1 = 'disbursed' THEN now() ELSE disbursed END,
+			updated = now()
+		WHERE id = $4 AND status = $5
+		RETURNING id, customer_id, product_id, amount, rate, term, method, status, manager_id, reason, disbursed, updated, created
+	`, to, managerID, reason, id, from))
+	if errors.Is(err, pgx.ErrNoRows) {
+		_, err = s.ByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidStatus
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
+
+// Submit отправляет черновик на рассмотрение.
+func (s *Service) Submit(ctx context.Context, id int64) (*Loan, error) {
+	item, err := s.ByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkCustomer(ctx, item.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, id, StatusDraft, StatusSubmitted, nil, "")
+}
+
+// Approve одобряет заявку менеджером managerID.
+func (s *Service) Approve(ctx context.Context, id int64, managerID int64) (*Loan, error) {
+	err := s.checkManager(ctx, managerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, id, StatusSubmitted, StatusApproved, &managerID, "")
+}
+
+// Reject отклоняет заявку менеджером managerID с причиной reason.
+func (s *Service) Reject(ctx context.Context, id int64, managerID int64, reason string) (*Loan, error) {
+	err := s.checkManager(ctx, managerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, id, StatusSubmitted, StatusRejected, &managerID, reason)
+}
+
+// Disburse выдаёт одобренный кредит. С даты выдачи отсчитывается график погашения.
+func (s *Service) Disburse(ctx context.Context, id int64) (*Loan, error) {
+	item, err := s.ByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkCustomer(ctx, item.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, id, StatusApproved, StatusDisbursed, nil, "")
+}
+
+// Schedule возвращает график погашения кредита.
+// Для ещё не выданного кредита график строится от текущей даты.
+func (s *Service) Schedule(ctx context.Context, id int64) (*Schedule, error) {
+	item, err := s.ByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if item.Disbursed != nil {
+		start = *item.Disbursed
+	}
+	return NewSchedule(item.Amount, item.Rate, item.Term, item.Method, start)
+}
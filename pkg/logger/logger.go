@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted - значение, которое выводится вместо секретов.
+const Redacted = "[REDACTED]"
+
+// sensitive - части имён полей, значения которых не попадают в лог.
+var sensitive = []string{"password", "token", "secret"}
+
+// sensitiveExact - имена полей, значения которых не попадают в лог.
+var sensitiveExact = map[string]bool{"pass": true, "authorization": true, "cookie": true, "dsn": true, "pan": true}
+
+type contextKey struct{ name string }
+
+var (
+	loggerKey    = &contextKey{"logger"}
+	requestIDKey = &contextKey{"request-id"}
+)
+
+// New создаёт JSON-логгер с уровнем level (debug, info, warn, error).
+// Значения полей с именами вроде password или token заменяются на [REDACTED].
+func New(level string) (*zap.Logger, error) {
+	var lvl zapcore.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(lvl)
+	cfg.Sampling = nil
+	cfg.DisableStacktrace = true
+	cfg.EncoderConfig.TimeKey = "time"
+	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	return cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactCore{Core: core}
+	}))
+}
+
+// WithContext сохраняет логгер в контексте.
+func WithContext(ctx context.Context, log *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, log)
+}
+
+// WithRequestID сохраняет в контексте id запроса и логгер, который добавляет его в каждую запись.
+func WithRequestID(ctx context.Context, log *zap.Logger, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, id)
+	return WithContext(ctx, log.With(zap.String("request_id", id)))
+}
+
+// RequestID возвращает id запроса из контекста или пустую строку.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// FromContext возвращает логгер из контекста, а если его нет - глобальный (zap.L).
+func FromContext(ctx context.Context) *zap.Logger {
+	if log, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
+		return log
+	}
+	return zap.L()
+}
+
+// Ctx - то же, что FromContext, но в виде SugaredLogger: logger.Ctx(ctx).Error(err).
+func Ctx(ctx context.Context) *zap.SugaredLogger {
+	return FromContext(ctx).Sugar()
+}
+
+// IsSensitive проверяет, что поле с именем key содержит секрет.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveExact[key] {
+		return true
+	}
+	for _, part := range sensitive {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactCore заменяет значения секретных полей перед записью.
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(redact(fields))}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, redact(fields))
+}
+
+func redact(fields []zapcore.Field) []zapcore.Field {
+	var result []zapcore.Field
+	for i, field := range fields {
+		if !IsSensitive(field.Key) {
+			continue
+		}
+		if result == nil {
+			result = append([]zapcore.Field(nil), fields...)
+		}
+		result[i] = zap.String(field.Key, Redacted)
+	}
+	if result == nil {
+		return fields
+	}
+	return result
+}
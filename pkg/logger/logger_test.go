@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestIsSensitive проверяет, какие имена полей считаются секретными.
+func TestIsSensitive(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"password", true},
+		{"Password", true},
+		{"new_password", true},
+		{"token", true},
+		{"refreshToken", true},
+		{"client_secret", true},
+		{"pass", true},
+		{"Authorization", true},
+		{"cookie", true},
+		{"dsn", true},
+		{"PAN", true},
+		{"login", false},
+		{"passport", false},
+		{"company", false},
+		{"panel", false},
+		{"cookies_enabled", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := IsSensitive(tt.key); got != tt.want {
+				t.Errorf("IsSensitive(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRedact проверяет, что заменяются только значения секретных полей, а исходный срез не меняется.
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []zapcore.Field
+		want   map[string]interface{}
+	}{
+		{"no fields", nil, map[string]interface{}{}},
+		{"nothing sensitive", []zapcore.Field{zap.String("login", "admin"), zap.Int64("id", 7)},
+			map[string]interface{}{"login": "admin", "id": int64(7)}},
+		{"password and token", []zapcore.Field{zap.String("login", "admin"), zap.String("password", "qwerty"), zap.String("token", "abc")},
+			map[string]interface{}{"login": "admin", "password": Redacted, "token": Redacted}},
+		{"non-string secret", []zapcore.Field{zap.Int("pan", 4276380000000000)},
+			map[string]interface{}{"pan": Redacted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]zapcore.Field(nil), tt.fields...)
+			got := redact(tt.fields)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d fields, want %d", len(got), len(tt.want))
+			}
+			encoder := zapcore.NewMapObjectEncoder()
+			for _, field := range got {
+				field.AddTo(encoder)
+			}
+			for key, value := range tt.want {
+				if encoder.Fields[key] != value {
+					t.Errorf("%s = %v, want %v", key, encoder.Fields[key], value)
+				}
+			}
+			for i := range original {
+				if !original[i].Equals(tt.fields[i]) {
+					t.Errorf("field %s was modified in place", original[i].Key)
+				}
+			}
+		})
+	}
+}
+
+// TestRedactCore проверяет замену секретов и в полях записи, и в полях из With.
+func TestRedactCore(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log := zap.New(&redactCore{Core: core}).With(zap.String("authorization", "Basic YWRtaW46cXdlcnR5"))
+	log.Info("login", zap.String("login", "admin"), zap.String("password", "qwerty"))
+	log.Sugar().Infow("customer", "token", "abc")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	first := entries[0].ContextMap()
+	if first["authorization"] != Redacted || first["password"] != Redacted || first["login"] != "admin" {
+		t.Errorf("got %v", first)
+	}
+	if second := entries[1].ContextMap(); second["token"] != Redacted {
+		t.Errorf("got %v", second)
+	}
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "crud"
+
+// Metrics - метрики приложения в формате Prometheus.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	// TokensIssued - выданные токены покупателей.
+	TokensIssued prometheus.Counter
+	// FailedLogins - попытки входа с неверным логином или паролем.
+	FailedLogins prometheus.Counter
+	// CustomersBlocked - заблокированные покупатели.
+	CustomersBlocked prometheus.Counter
+	// LegacyRequests - запросы к устаревшим маршрутам по шаблону маршрута.
+	LegacyRequests *prometheus.CounterVec
+}
+
+// New создаёт метрики и регистрирует сборщики, в том числе статистику пула соединений.
+func New(pool *pgxpool.Pool) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by route template, method and status.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		TokensIssued: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tokens_issued_total",
+			Help:      "Number of customer tokens issued.",
+		}),
+		FailedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failed_logins_total",
+			Help:      "Number of customer logins rejected because of a wrong login or password.",
+		}),
+		CustomersBlocked: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "customers_blocked_total",
+			Help:      "Number of customers blocked.",
+		}),
+		LegacyRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_legacy_requests_total",
+			Help:      "Number of requests to deprecated unversioned routes by route template.",
+		}, []string{"route"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newPoolCollector(pool),
+		m.requests,
+		m.duration,
+		m.TokensIssued,
+		m.FailedLogins,
+		m.CustomersBlocked,
+		m.LegacyRequests,
+	)
+	return m
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registerer позволяет другим пакетам регистрировать свои метрики.
+func (m *Metrics) Registerer() prometheus.Registerer {
+	return m.registry
+}
+
+// Middleware считает запросы и их длительность.
+// Подключается через mux.Router.Use, чтобы в метке route был шаблон маршрута
+// (/customers/{id}), а не конкретный путь.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		next.ServeHTTP(recorder, request)
+
+		route := "unknown"
+		if current := mux.CurrentRoute(request); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		status := strconv.Itoa(recorder.status)
+		m.requests.WithLabelValues(route, request.Method, status).Inc()
+		m.duration.WithLabelValues(route, request.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder запоминает код ответа.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
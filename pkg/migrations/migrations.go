@@ -0,0 +1,211 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// ErrInternal возвращается, когда происходит внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// lockID - ключ advisory-блокировки, чтобы миграции не применялись параллельно.
+const lockID = 7206541
+
+// Migration - изменение схемы БД из файла sql/<версия>_<имя>.sql.
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	SQL     string `json:"-"`
+}
+
+// All возвращает все миграции по возрастанию версии.
+func All() ([]*Migration, error) {
+	names, err := fs.Glob(files, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*Migration, 0, len(names))
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+		data, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &Migration{Version: version, Name: parts[1], SQL: string(data)})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Version < items[j].Version })
+	return items, nil
+}
+
+// Service - сервис миграций.
+type Service struct {
+	pool *pgxpool.Pool
+}
+
+// NewService - конструктор сервиса.
+func NewService(pool *pgxpool.Pool) *Service {
+	return &Service{pool: pool}
+}
+
+// Pending возвращает ещё не применённые миграции.
+func (s *Service) Pending(ctx context.Context) ([]*Migration, error) {
+	items, err := All()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	var exists bool
+	err = s.pool.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	if !exists {
+		return items, nil
+	}
+
+	applied, err := appliedVersions(ctx, s.pool)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	return pending(items, applied), nil
+}
+
+// Up применяет недостающие миграции, каждую в отдельной транзакции,
+// и возвращает применённые.
+func (s *Service) Up(ctx context.Context) ([]*Migration, error) {
+	items, err := All()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer func() {
+		_, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			version integer primary key,
+			name text not null,
+			applied timestamp not null default CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	applied, err := appliedVersions(ctx, conn.Conn())
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	done := []*Migration{}
+	for _, item := range pending(items, applied) {
+		err = apply(ctx, conn.Conn(), item)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return done, fmt.Errorf("%w: migration %d_%s", ErrInternal, item.Version, item.Name)
+		}
+		logger.Ctx(ctx).Infow("applied migration", "version", item.Version, "name", item.Name)
+		done = append(done, item)
+	}
+	return done, nil
+}
+
+func apply(ctx context.Context, conn *pgx.Conn, item *Migration) (err error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rerr := tx.Rollback(ctx); rerr != nil {
+				logger.Ctx(ctx).Error(rerr)
+			}
+		}
+	}()
+
+	_, err = tx.Exec(ctx, item.SQL)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, item.Version, item.Name)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func appliedVersions(ctx context.Context, db querier) (map[int]bool, error) {
+	rows, err := db.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		err = rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func pending(items []*Migration, applied map[int]bool) []*Migration {
+	result := []*Migration{}
+	for _, item := range items {
+		if !applied[item.Version] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
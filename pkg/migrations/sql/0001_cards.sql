@@ -0,0 +1,21 @@
+CREATE TABLE cards
+(
+    id bigserial primary key,
+    customer_id bigint not null references customers,
+    pan text not null,
+    pan_hash text not null UNIQUE,
+    balance bigint not null default 0 CHECK (balance >= 0),
+    daily_limit bigint not null default 500000 CHECK (daily_limit >= 0),
+    expire timestamp not null,
+    active boolean not null default true,
+    created timestamp not null default CURRENT_TIMESTAMP
+);
+
+CREATE TABLE card_transactions
+(
+    id bigserial primary key,
+    from_card_id bigint not null references cards,
+    to_card_id bigint not null references cards,
+    amount bigint not null CHECK (amount > 0),
+    created timestamp not null default CURRENT_TIMESTAMP
+);
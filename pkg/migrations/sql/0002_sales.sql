@@ -0,0 +1,11 @@
+CREATE TABLE sales
+(
+    id bigserial primary key,
+    manager_id bigint not null references managers,
+    customer_id bigint not null references customers,
+    product text not null,
+    amount bigint not null CHECK (amount > 0),
+    created timestamp not null default CURRENT_TIMESTAMP
+);
+
+CREATE INDEX sales_manager_created_idx ON sales (manager_id, created);
@@ -0,0 +1,35 @@
+CREATE TABLE products
+(
+    id bigserial primary key,
+    name text not null,
+    category text not null CHECK (category IN ('deposit', 'loan', 'card')),
+    price bigint not null default 0 CHECK (price >= 0),
+    rate double precision not null default 0 CHECK (rate >= 0),
+    active boolean not null default true,
+    valid_from timestamp not null default CURRENT_TIMESTAMP,
+    valid_to timestamp,
+    created timestamp not null default CURRENT_TIMESTAMP
+);
+
+CREATE TABLE product_prices
+(
+    id bigserial primary key,
+    product_id bigint not null references products ON DELETE CASCADE,
+    price bigint not null,
+    rate double precision not null,
+    created timestamp not null default CURRENT_TIMESTAMP
+);
+
+CREATE TABLE product_attachments
+(
+    id bigserial primary key,
+    product_id bigint not null references products ON DELETE CASCADE,
+    name text not null,
+    content_type text not null,
+    path text not null,
+    size bigint not null,
+    checksum text not null,
+    created timestamp not null default CURRENT_TIMESTAMP
+);
+
+ALTER TABLE sales ADD COLUMN product_id bigint references products ON DELETE SET NULL;
@@ -0,0 +1,16 @@
+CREATE TABLE loans
+(
+    id bigserial primary key,
+    customer_id bigint not null references customers,
+    product_id bigint references products ON DELETE SET NULL,
+    amount bigint not null CHECK (amount > 0),
+    rate double precision not null CHECK (rate >= 0),
+    term integer not null CHECK (term > 0),
+    method text not null CHECK (method IN ('annuity', 'differentiated')),
+    status text not null default 'draft' CHECK (status IN ('draft', 'submitted', 'approved', 'rejected', 'disbursed')),
+    manager_id bigint references managers,
+    reason text not null default '',
+    disbursed timestamp,
+    updated timestamp not null default CURRENT_TIMESTAMP,
+    created timestamp not null default CURRENT_TIMESTAMP
+);
@@ -0,0 +1,21 @@
+CREATE TABLE exchange_rates
+(
+    id bigserial primary key,
+    base text not null,
+    quote text not null,
+    rate double precision not null CHECK (rate > 0),
+    spread double precision not null default 0 CHECK (spread >= 0 AND spread < 100),
+    date date not null,
+    created timestamp not null default CURRENT_TIMESTAMP,
+    UNIQUE (base, quote, date)
+);
+
+ALTER TABLE cards ADD COLUMN currency text not null default 'TJS';
+ALTER TABLE card_transactions
+    ADD COLUMN currency text not null default 'TJS',
+    ADD COLUMN to_amount bigint not null default 0,
+    ADD COLUMN to_currency text not null default 'TJS',
+    ADD COLUMN rate double precision not null default 1,
+    ADD COLUMN spread double precision not null default 0;
+ALTER TABLE products ADD COLUMN currency text not null default 'TJS';
+ALTER TABLE product_prices ADD COLUMN currency text not null default 'TJS';
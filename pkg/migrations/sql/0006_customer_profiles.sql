@@ -0,0 +1,26 @@
+CREATE TABLE customer_profiles
+(
+    customer_id bigint primary key references customers ON DELETE CASCADE,
+    birth_date date not null,
+    national_id text not null UNIQUE,
+    address text not null,
+    email text not null,
+    kyc_status text not null default 'pending' CHECK (kyc_status IN ('pending', 'verified', 'rejected')),
+    manager_id bigint references managers,
+    reason text not null default '',
+    updated timestamp not null default CURRENT_TIMESTAMP,
+    created timestamp not null default CURRENT_TIMESTAMP
+);
+
+CREATE TABLE customer_documents
+(
+    id bigserial primary key,
+    customer_id bigint not null references customers ON DELETE CASCADE,
+    kind text not null CHECK (kind IN ('passport', 'other')),
+    name text not null,
+    content_type text not null,
+    path text not null,
+    size bigint not null,
+    checksum text not null,
+    created timestamp not null default CURRENT_TIMESTAMP
+);
@@ -0,0 +1,9 @@
+CREATE TABLE rate_limits
+(
+    key text primary key,
+    tokens double precision not null,
+    updated timestamp not null default CURRENT_TIMESTAMP,
+    full_at timestamp not null
+);
+
+CREATE INDEX rate_limits_full_at_idx ON rate_limits (full_at);
@@ -0,0 +1,9 @@
+CREATE TABLE managers_sessions
+(
+    token text primary key,
+    manager_id bigint not null references managers ON DELETE CASCADE,
+    expire timestamp not null,
+    created timestamp not null default CURRENT_TIMESTAMP
+);
+
+CREATE INDEX managers_sessions_manager_id_idx ON managers_sessions (manager_id);
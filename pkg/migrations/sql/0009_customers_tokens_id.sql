@@ -0,0 +1,2 @@
+-- id сессии, чтобы покупатель мог завершить её, не видя чужих токенов
+ALTER TABLE customers_tokens ADD COLUMN id bigserial UNIQUE;
@@ -0,0 +1,11 @@
+CREATE TABLE customers_audit
+(
+    id bigserial primary key,
+    customer_id bigint not null,
+    action text not null,
+    actor text not null,
+    request_id text not null default '',
+    created timestamp not null default CURRENT_TIMESTAMP
+);
+
+CREATE INDEX customers_audit_customer_id_idx ON customers_audit (customer_id);
@@ -0,0 +1,159 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownCurrency возвращается, когда валюта не поддерживается.
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// ErrCurrencyMismatch возвращается при арифметике над суммами в разных валютах.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ErrInvalidAmount возвращается, когда сумму не удалось разобрать.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Коды поддерживаемых валют по ISO 4217.
+const (
+	TJS = "TJS"
+	USD = "USD"
+	RUB = "RUB"
+	EUR = "EUR"
+)
+
+// Default - валюта по умолчанию для счетов и цен.
+const Default = TJS
+
+// Currency описывает валюту по ISO 4217.
+type Currency struct {
+	Code   string `json:"code"`
+	Number int    `json:"number"`
+	Minor  int    `json:"minor"`
+	Name   string `json:"name"`
+}
+
+// currencies - поддерживаемые валюты.
+var currencies = map[string]Currency{
+	TJS: {Code: TJS, Number: 972, Minor: 2, Name: "Somoni"},
+	USD: {Code: USD, Number: 840, Minor: 2, Name: "US Dollar"},
+	RUB: {Code: RUB, Number: 643, Minor: 2, Name: "Russian Ruble"},
+	EUR: {Code: EUR, Number: 978, Minor: 2, Name: "Euro"},
+}
+
+// Lookup возвращает валюту по коду ISO 4217.
+func Lookup(code string) (Currency, error) {
+	currency, ok := currencies[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, ErrUnknownCurrency
+	}
+	return currency, nil
+}
+
+// Valid возвращает true, если валюта поддерживается.
+func Valid(code string) bool {
+	_, err := Lookup(code)
+	return err == nil
+}
+
+// Money - сумма в минимальных единицах валюты (дирамах, центах, копейках).
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New создаёт сумму amount минимальных единиц валюты currency.
+func New(amount int64, currency string) (Money, error) {
+	c, err := Lookup(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: c.Code}, nil
+}
+
+// Parse разбирает десятичную запись суммы (например, "12.50") в валюте currency.
+func Parse(value string, currency string) (Money, error) {
+	c, err := Lookup(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+	whole, fraction := value, ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		whole, fraction = value[:i], value[i+1:]
+	}
+	if whole == "" || len(fraction) > c.Minor {
+		return Money{}, ErrInvalidAmount
+	}
+	fraction += strings.Repeat("0", c.Minor-len(fraction))
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil || amount < 0 {
+		return Money{}, ErrInvalidAmount
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: c.Code}, nil
+}
+
+// String возвращает сумму в виде "12.50 TJS".
+func (m Money) String() string {
+	c, err := Lookup(m.Currency)
+	if err != nil {
+		return strconv.FormatInt(m.Amount, 10) + " " + m.Currency
+	}
+
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	if c.Minor == 0 {
+		return sign + digits + " " + c.Code
+	}
+	if len(digits) <= c.Minor {
+		digits = strings.Repeat("0", c.Minor-len(digits)+1) + digits
+	}
+	point := len(digits) - c.Minor
+	return sign + digits[:point] + "." + digits[point:] + " " + c.Code
+}
+
+// Add складывает суммы в одной валюте.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Sub вычитает сумму в той же валюте.
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}, nil
+}
+
+// Convert переводит сумму в валюту to по курсу rate (сколько единиц to за одну единицу
+// исходной валюты), уменьшенному на spread процентов. Результат округляется
+// до минимальной единицы валюты to.
+func (m Money) Convert(to string, rate float64, spread float64) (Money, error) {
+	from, err := Lookup(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	target, err := Lookup(to)
+	if err != nil {
+		return Money{}, err
+	}
+
+	value := float64(m.Amount) / math.Pow10(from.Minor) * rate * (1 - spread/100)
+	return Money{Amount: int64(math.Round(value * math.Pow10(target.Minor))), Currency: target.Code}, nil
+}
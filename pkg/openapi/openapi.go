@@ -0,0 +1,347 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version - версия спецификации OpenAPI.
+const Version = "3.0.3"
+
+// Document - документ OpenAPI 3 (только используемые в проекте поля).
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []*Tag               `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	types map[reflect.Type]string
+}
+
+// Info - сведения об API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Tag - группа операций.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem - операции одного пути по методам (get, post, ...).
+type PathItem map[string]*Operation
+
+// Operation - описание одной операции.
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter - параметр пути, запроса или заголовка.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody - тело запроса по типам содержимого.
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// Response - ответ с кодом.
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Header - заголовок ответа.
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType - схема содержимого одного типа.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema - JSON-схема значения.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Components - переиспользуемые схемы.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme - способ аутентификации.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// New создаёт пустой документ.
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+		},
+		types: make(map[reflect.Type]string),
+	}
+}
+
+// pathParam - параметр шаблона gorilla/mux: {name} или {name:regexp}.
+var pathParam = regexp.MustCompile(`\{([^{}:]+)(:[^{}]*)?\}`)
+
+// Path переводит шаблон маршрута gorilla/mux в путь OpenAPI, убирая регулярные выражения.
+func Path(template string) string {
+	return pathParam.ReplaceAllString(template, "{$1}")
+}
+
+// Add добавляет копию операции op как method для шаблона маршрута template.
+// Недостающие параметры пути добавляются сами: целые, если имя кончается на id/Id.
+func (d *Document) Add(method string, template string, op *Operation) {
+	path := Path(template)
+	copied := *op
+	copied.Parameters = append([]*Parameter(nil), op.Parameters...)
+	op = &copied
+	for _, match := range pathParam.FindAllStringSubmatch(template, -1) {
+		if hasParameter(op, match[1], "path") {
+			continue
+		}
+		schema := &Schema{Type: "string"}
+		if strings.HasSuffix(strings.ToLower(match[1]), "id") {
+			schema = &Schema{Type: "integer", Format: "int64"}
+		}
+		op.Parameters = append(op.Parameters, &Parameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+	if op.Responses == nil {
+		op.Responses = map[string]*Response{"200": {Description: "OK"}}
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+// Has сообщает, описана ли операция method для шаблона маршрута template.
+func (d *Document) Has(method string, template string) bool {
+	item, ok := d.Paths[Path(template)]
+	if !ok {
+		return false
+	}
+	_, ok = (*item)[strings.ToLower(method)]
+	return ok
+}
+
+// Operations возвращает описанные операции в виде "GET /path", отсортированные по пути.
+func (d *Document) Operations() []string {
+	result := []string{}
+	for path, item := range d.Paths {
+		for method := range *item {
+			result = append(result, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func hasParameter(op *Operation, name string, in string) bool {
+	for _, param := range op.Parameters {
+		if param.Name == name && param.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON - ответ со схемой значения v: application/json или application/msgpack по заголовку Accept.
+func (d *Document) JSON(description string, v interface{}) *Response {
+	schema := d.Schema(v)
+	return &Response{
+		Description: description,
+		Content: map[string]*MediaType{
+			"application/json":    {Schema: schema},
+			"application/msgpack": {Schema: schema},
+		},
+	}
+}
+
+// JSONBody - тело запроса со схемой значения v. Кроме JSON те же поля принимаются
+// в msgpack и в полях формы (см. пакет binding).
+func (d *Document) JSONBody(v interface{}) *RequestBody {
+	schema := d.Schema(v)
+	return &RequestBody{
+		Required: true,
+		Content: map[string]*MediaType{
+			"application/json":                  {Schema: schema},
+			"application/msgpack":               {Schema: schema},
+			"application/x-www-form-urlencoded": {Schema: schema},
+			"multipart/form-data":               {Schema: schema},
+		},
+	}
+}
+
+// Form - тело запроса multipart/form-data с полями fields; файлы - схема File.
+func Form(fields map[string]*Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content: map[string]*MediaType{
+			"multipart/form-data": {Schema: &Schema{Type: "object", Properties: fields}},
+		},
+	}
+}
+
+// Empty - ответ без тела.
+func Empty(description string) *Response {
+	return &Response{Description: description}
+}
+
+// Content - ответ с произвольным типом содержимого (файлы, CSV).
+func Content(description string, contentTypes ...string) *Response {
+	content := make(map[string]*MediaType, len(contentTypes))
+	for _, contentType := range contentTypes {
+		content[contentType] = &MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+	}
+	return &Response{Description: description, Content: content}
+}
+
+// Query - необязательный параметр строки запроса.
+func Query(name string, schema *Schema, description string) *Parameter {
+	return &Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// String, Integer, Number, Boolean, Date и File - схемы простых значений.
+var (
+	String  = &Schema{Type: "string"}
+	File    = &Schema{Type: "string", Format: "binary"}
+	Integer = &Schema{Type: "integer", Format: "int64"}
+	Number  = &Schema{Type: "number"}
+	Boolean = &Schema{Type: "boolean"}
+	Date    = &Schema{Type: "string", Format: "date"}
+)
+
+// Schema возвращает схему значения v. Структуры попадают в components.schemas
+// под именем типа и подставляются ссылкой; имена полей берутся из тегов json.
+func (d *Document) Schema(v interface{}) *Schema {
+	return d.schema(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (d *Document) schema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := d.schema(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		copied := *schema
+		copied.Nullable = true
+		return &copied
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		return &Schema{Ref: "#/components/schemas/" + d.component(t)}
+	}
+	return &Schema{}
+}
+
+// component регистрирует схему структуры и возвращает её имя.
+// Одноимённые типы разных пакетов различаются префиксом пакета.
+func (d *Document) component(t reflect.Type) string {
+	if name, ok := d.types[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := d.Components.Schemas[name]; taken || name == "" {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	d.types[t] = name
+
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.Components.Schemas[name] = schema
+	d.fields(t, schema)
+	return name
+}
+
+func (d *Document) fields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			d.fields(field.Type, schema)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		schema.Properties[tag] = d.schema(field.Type)
+	}
+}
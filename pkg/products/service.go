@@ -0,0 +1,476 @@
+package products
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/files"
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/money"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrNotFound возвращается, когда продукт не найден.
+var ErrNotFound = errors.New("product not found")
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrInvalidProduct возвращается, когда продукт заполнен неверно.
+var ErrInvalidProduct = errors.New("invalid product")
+
+// ErrAttachmentNotFound возвращается, когда вложение не найдено.
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// ErrAttachmentTooLarge возвращается, когда вложение больше MaxAttachmentSize.
+var ErrAttachmentTooLarge = errors.New("attachment is too large")
+
+// MaxAttachmentSize - максимальный размер вложения продукта.
+const MaxAttachmentSize = 10 << 20
+
+// attachmentsPrefix - подкаталог хранилища для вложений продуктов.
+const attachmentsPrefix = "products"
+
+// attachmentTypes - типы содержимого вложений, которые можно показывать в браузере.
+// Остальные файлы сохраняются и отдаются как application/octet-stream.
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// Категории продуктов.
+const (
+	CategoryDeposit = "deposit"
+	CategoryLoan    = "loan"
+	CategoryCard    = "card"
+)
+
+// Service описывает сервис работы с каталогом продуктов.
+type Service struct {
+	pool    *pgxpool.Pool
+	storage *files.Storage
+}
+
+// NewService создаёт сервис.
+func NewService(pool *pgxpool.Pool, storage *files.Storage) *Service {
+	return &Service{pool: pool, storage: storage}
+}
+
+// Product представляет банковский продукт: вклад, кредит или карту.
+// Для вкладов и кредитов задаётся процентная ставка Rate, для карт - цена Price
+// в минимальных единицах валюты Currency.
+// ValidTo == nil означает бессрочный продукт.
+type Product struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Category  string     `json:"category"`
+	Price     int64      `json:"price"`
+	Currency  string     `json:"currency"`
+	Rate      float64    `json:"rate"`
+	Active    bool       `json:"active"`
+	ValidFrom time.Time  `json:"validFrom"`
+	ValidTo   *time.Time `json:"validTo"`
+	Created   time.Time  `json:"created"`
+}
+
+// Price - запись истории цен продукта.
+type Price struct {
+	ProductID int64     `json:"productId"`
+	Price     int64     `json:"price"`
+	Currency  string    `json:"currency"`
+	Rate      float64   `json:"rate"`
+	Created   time.Time `json:"created"`
+}
+
+// Attachment - изображение или документ, приложенный к продукту.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"productId"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum"`
+	Created     time.Time `json:"created"`
+
+	path string
+}
+
+// Inline возвращает true, если вложение можно открыть в браузере, а не только скачать.
+func (a *Attachment) Inline() bool {
+	return attachmentTypes[a.ContentType]
+}
+
+// Available возвращает true, если продукт активен и действует в момент now.
+func (p *Product) Available(now time.Time) bool {
+	if !p.Active || now.Before(p.ValidFrom) {
+		return false
+	}
+	return p.ValidTo == nil || now.Before(*p.ValidTo)
+}
+
+// validCategory проверяет, что категория продукта известна.
+func validCategory(category string) bool {
+	switch category {
+	case CategoryDeposit, CategoryLoan, CategoryCard:
+		return true
+	}
+	return false
+}
+
+// selectProducts - общая часть запросов продуктов.
+const selectProducts = `
+	SELECT p.id, p.name, p.category, p.price, p.currency, p.rate, p.active, p.valid_from, p.valid_to, p.created FROM products p
+`
+
+func scanProduct(row pgx.Row) (*Product, error) {
+	item := &Product{}
+	err := row.Scan(&item.ID, &item.Name, &item.Category, &item.Price, &item.Currency, &item.Rate,
+		&item.Active, &item.ValidFrom, &item.ValidTo, &item.Created)
+	return item, err
+}
+
+// queryProducts выполняет запрос, возвращающий строки selectProducts.
+func (s *Service) queryProducts(ctx context.Context, sql string, args ...interface{}) ([]*Product, error) {
+	items := make([]*Product, 0)
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanProduct(rows)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// All возвращает продукты каталога. Если category не пустая, только этой категории;
+// если onlyAvailable, только активные и действующие сейчас.
+func (s *Service) All(ctx context.Context, category string, onlyAvailable bool) ([]*Product, error) {
+	return s.queryProducts(ctx, selectProducts+`
+		WHERE ($1 = '' OR p.category = $1)
+		AND (NOT $2 OR (p.active AND p.valid_from <= now() AND (p.valid_to IS NULL OR p.valid_to > now())))
+		ORDER BY p.id
+	`, category, onlyAvailable)
+}
+
+// ByID возвращает продукт по идентификатору.
+func (s *Service) ByID(ctx context.Context, id int64) (*Product, error) {
+	item, err := scanProduct(s.pool.QueryRow(ctx, selectProducts+` WHERE p.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
+
+// ByCustomer возвращает продукты, которые менеджеры продали покупателю.
+func (s *Service) ByCustomer(ctx context.Context, customerID int64) ([]*Product, error) {
+	return s.queryProducts(ctx, selectProducts+`
+		WHERE p.id IN (SELECT product_id FROM sales WHERE customer_id = $1)
+		ORDER BY p.id
+	`, customerID)
+}
+
+// Save создаёт (при ID == 0) или обновляет продукт.
+// При создании и при каждом изменении цены или ставки пишется запись в историю цен.
+func (s *Service) Save(ctx context.Context, item *Product) (*Product, error) {
+	if item.Name == "" || !validCategory(item.Category) || item.Price < 0 || item.Rate < 0 {
+		return nil, ErrInvalidProduct
+	}
+	if item.Currency == "" {
+		item.Currency = money.Default
+	}
+	c, err := money.Lookup(item.Currency)
+	if err != nil {
+		return nil, ErrInvalidProduct
+	}
+	item.Currency = c.Code
+	if item.ValidFrom.IsZero() {
+		item.ValidFrom = time.Now()
+	}
+	if item.ValidTo != nil && !item.ValidTo.After(item.ValidFrom) {
+		return nil, ErrInvalidProduct
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	var res *Product
+	priceChanged := true
+	if item.ID == 0 {
+		res, err = scanProduct(tx.QueryRow(ctx, `
+			INSERT INTO products(name, category, price, currency, rate, active, valid_from, valid_to)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, name, category, price, currency, rate, active, valid_from, valid_to, created
+		`, item.Name, item.Category, item.Price, item.Currency, item.Rate, item.Active, item.ValidFrom, item.ValidTo))
+	} else {
+		var price int64
+		var currency string
+		var rate float64
+		err = tx.QueryRow(ctx, `
+			SELECT price, currency, rate FROM products WHERE id = $1 FOR UPDATE
+		`, item.ID).Scan(&price, &currency, &rate)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		priceChanged = price != item.Price || currency != item.Currency || rate != item.Rate
+
+		res, err = scanProduct(tx.QueryRow(ctx, `
+			UPDATE products SET name = $1, category = $2, price = $3, currency = $4, rate = $5, active = $6,
+				valid_from = $7, valid_to = $8
+			WHERE id = $9
+			RETURNING id, name, category, price, currency, rate, active, valid_from, valid_to, created
+		`, item.Name, item.Category, item.Price, item.Currency, item.Rate, item.Active, item.ValidFrom, item.ValidTo, item.ID))
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	if priceChanged {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO product_prices(product_id, price, currency, rate) VALUES ($1, $2, $3, $4)
+		`, res.ID, res.Price, res.Currency, res.Rate)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return res, nil
+}
+
+// RemoveByID удаляет продукт вместе с его вложениями.
+func (s *Service) RemoveByID(ctx context.Context, id int64) error {
+	attachments, err := s.Attachments(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM products WHERE id = $1`, id)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	for _, attachment := range attachments {
+		err = s.storage.Remove(attachment.path)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+		}
+	}
+
+	return nil
+}
+
+// Prices возвращает историю цен продукта, начиная с самой новой.
+func (s *Service) Prices(ctx context.Context, id int64) ([]*Price, error) {
+	_, err := s.ByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*Price, 0)
+	rows, err := s.pool.Query(ctx, `
+		SELECT product_id, price, currency, rate, created FROM product_prices WHERE product_id = $1 ORDER BY created DESC, id DESC
+	`, id)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &Price{}
+		err = rows.Scan(&item.ProductID, &item.Price, &item.Currency, &item.Rate, &item.Created)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// AddAttachment сохраняет файл на диск и прикрепляет его к продукту.
+// Тип определяется по содержимому файла, а не по заголовку клиента;
+// всё, кроме attachmentTypes, сохраняется как application/octet-stream.
+func (s *Service) AddAttachment(ctx context.Context, productID int64, name string, reader io.Reader) (*Attachment, error) {
+	_, err := s.ByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReaderSize(reader, 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	contentType := http.DetectContentType(head)
+	if !attachmentTypes[contentType] {
+		contentType = "application/octet-stream"
+	}
+
+	file, err := s.storage.Save(attachmentsPrefix, name, buffered, MaxAttachmentSize)
+	if errors.Is(err, files.ErrTooLarge) {
+		return nil, ErrAttachmentTooLarge
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	item := &Attachment{ProductID: productID, Name: name, ContentType: contentType, Size: file.Size, Checksum: file.Checksum, path: file.Path}
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO product_attachments(product_id, name, content_type, path, size, checksum) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created
+	`, productID, name, contentType, file.Path, file.Size, file.Checksum).Scan(&item.ID, &item.Created)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		if rerr := s.storage.Remove(file.Path); rerr != nil {
+			logger.Ctx(ctx).Error(rerr)
+		}
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
+
+// Attachments возвращает вложения продукта.
+func (s *Service) Attachments(ctx context.Context, productID int64) ([]*Attachment, error) {
+	items := make([]*Attachment, 0)
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, product_id, name, content_type, path, size, checksum, created
+		FROM product_attachments WHERE product_id = $1 ORDER BY id
+	`, productID)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &Attachment{}
+		err = rows.Scan(&item.ID, &item.ProductID, &item.Name, &item.ContentType, &item.path, &item.Size, &item.Checksum, &item.Created)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// attachment возвращает вложение продукта по идентификатору.
+func (s *Service) attachment(ctx context.Context, productID int64, id int64) (*Attachment, error) {
+	item := &Attachment{}
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, product_id, name, content_type, path, size, checksum, created
+		FROM product_attachments WHERE id = $1 AND product_id = $2
+	`, id, productID).Scan(&item.ID, &item.ProductID, &item.Name, &item.ContentType, &item.path, &item.Size, &item.Checksum, &item.Created)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
+
+// OpenAttachment открывает файл вложения для чтения. Файл нужно закрыть.
+func (s *Service) OpenAttachment(ctx context.Context, productID int64, id int64) (*Attachment, *os.File, error) {
+	item, err := s.attachment(ctx, productID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := s.storage.Open(item.path)
+	if errors.Is(err, files.ErrNotFound) {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, nil, ErrInternal
+	}
+
+	return item, file, nil
+}
+
+// RemoveAttachment удаляет вложение продукта вместе с файлом.
+func (s *Service) RemoveAttachment(ctx context.Context, productID int64, id int64) error {
+	item, err := s.attachment(ctx, productID, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.pool.Exec(ctx, `DELETE FROM product_attachments WHERE id = $1`, id)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+
+	err = s.storage.Remove(item.path)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/realip"
+	"github.com/gorilla/mux"
+)
+
+// Ключи, по которым считаются запросы.
+const (
+	// KeyIP - адрес клиента.
+	KeyIP = "ip"
+	// KeyCustomer - id покупателя по токену из заголовка Authorization: Bearer <token>.
+	KeyCustomer = "customer"
+	// KeyManager - логин менеджера из проверенного клиентского сертификата.
+	// Логин из Basic-авторизации не используется: ограничение проверяется до пароля,
+	// и клиент мог бы менять логин в каждом запросе.
+	KeyManager = "manager"
+)
+
+// ErrInvalidRule возвращается, когда правило задано неверно.
+var ErrInvalidRule = errors.New("invalid rate limit rule")
+
+// Rule - ограничение для маршрута: не больше Limit запросов за Period
+// с каждого ключа, кратковременно - до Burst запросов подряд.
+type Rule struct {
+	Method string
+	Route  string
+	Key    string
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// Validate проверяет правило.
+func (r *Rule) Validate() error {
+	switch r.Key {
+	case KeyIP, KeyCustomer, KeyManager:
+	default:
+		return fmt.Errorf("%w: %s %s: unknown key %q", ErrInvalidRule, r.Method, r.Route, r.Key)
+	}
+	if r.Route == "" || r.Limit <= 0 || r.Period <= 0 || r.Burst < 0 {
+		return fmt.Errorf("%w: %s %s: route, limit and period are required", ErrInvalidRule, r.Method, r.Route)
+	}
+	return nil
+}
+
+// bucket возвращает параметры корзины: ёмкость и скорость пополнения в токенах в секунду.
+func (r *Rule) bucket() Bucket {
+	capacity := r.Burst
+	if capacity == 0 {
+		capacity = r.Limit
+	}
+	return Bucket{Capacity: float64(capacity), Rate: float64(r.Limit) / r.Period.Seconds()}
+}
+
+// Bucket - параметры корзины токенов.
+type Bucket struct {
+	Capacity float64
+	Rate     float64
+}
+
+// Result - результат попытки взять токен.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset - через сколько корзина наполнится полностью.
+	Reset time.Duration
+	// RetryAfter - через сколько появится токен, если запрос отклонён.
+	RetryAfter time.Duration
+}
+
+// take пополняет корзину с tokens токенов за elapsed и пытается взять один токен.
+// Возвращает новое число токенов и результат.
+func (b Bucket) take(tokens float64, elapsed time.Duration) (float64, *Result) {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	tokens = math.Min(b.Capacity, tokens+elapsed.Seconds()*b.Rate)
+
+	result := &Result{}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / b.Rate)
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = seconds((b.Capacity - tokens) / b.Rate)
+	return tokens, result
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
+
+// Store хранит состояние корзин. Хранилище в Postgres позволяет
+// нескольким экземплярам сервиса делить одни и те же ограничения.
+type Store interface {
+	Take(ctx context.Context, key string, bucket Bucket) (*Result, error)
+}
+
+// CustomerResolver возвращает id покупателя по токену.
+type CustomerResolver func(ctx context.Context, token string) (int64, error)
+
+// Limiter ограничивает частоту запросов по правилам.
+type Limiter struct {
+	store    Store
+	rules    map[string]*Rule
+	resolver *realip.Resolver
+	customer CustomerResolver
+}
+
+// New создаёт Limiter.
+func New(store Store, rules []*Rule, resolver *realip.Resolver, customer CustomerResolver) (*Limiter, error) {
+	l := &Limiter{store: store, rules: make(map[string]*Rule), resolver: resolver, customer: customer}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		l.rules[ruleKey(rule.Method, rule.Route)] = rule
+	}
+	return l, nil
+}
+
+// ruleKey - правило без метода действует на все методы маршрута.
+func ruleKey(method, route string) string {
+	return strings.ToUpper(method) + " " + route
+}
+
+func (l *Limiter) rule(request *http.Request) *Rule {
+	current := mux.CurrentRoute(request)
+	if current == nil {
+		return nil
+	}
+	route, err := current.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+	if rule, ok := l.rules[ruleKey(request.Method, route)]; ok {
+		return rule
+	}
+	return l.rules[ruleKey("", route)]
+}
+
+// key возвращает ключ клиента по проверенному удостоверению; если его нет, используется адрес.
+func (l *Limiter) key(request *http.Request, rule *Rule) string {
+	switch rule.Key {
+	case KeyManager:
+		if request.TLS != nil && len(request.TLS.VerifiedChains) > 0 {
+			return "manager:" + request.TLS.VerifiedChains[0][0].Subject.CommonName
+		}
+	case KeyCustomer:
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if token != "" && l.customer != nil {
+			if id, err := l.customer(request.Context(), token); err == nil {
+				return "customer:" + strconv.FormatInt(id, 10)
+			}
+		}
+	}
+	return "ip:" + l.resolver.ClientIP(request)
+}
+
+// Middleware - middleware для mux.Router.Use. Для маршрутов с правилом добавляет заголовки
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy,
+// а при превышении отвечает 429 с Retry-After. Если хранилище недоступно, запрос пропускается.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		rule := l.rule(request)
+		if rule == nil {
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		bucket := rule.bucket()
+		key := ruleKey(rule.Method, rule.Route) + " " + l.key(request, rule)
+		result, err := l.store.Take(request.Context(), key, bucket)
+		if err != nil {
+			logger.Ctx(request.Context()).Error(err)
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		header := writer.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(int(bucket.Capacity)))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", ceilSeconds(result.Reset))
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s;burst=%d", rule.Limit, ceilSeconds(rule.Period), int(bucket.Capacity)))
+		if !result.Allowed {
+			header.Set("Retry-After", ceilSeconds(result.RetryAfter))
+			http.Error(writer, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
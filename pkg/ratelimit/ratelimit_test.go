@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/realip"
+	"github.com/gorilla/mux"
+)
+
+// TestBucketTake проверяет пополнение корзины и расчёт Remaining, Reset и RetryAfter.
+func TestBucketTake(t *testing.T) {
+	bucket := Bucket{Capacity: 3, Rate: 1}
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		wantTokens float64
+		want       Result
+	}{
+		{
+			name: "full", tokens: 3, wantTokens: 2,
+			want: Result{Allowed: true, Remaining: 2, Reset: time.Second},
+		},
+		{
+			name: "empty", tokens: 0, wantTokens: 0,
+			want: Result{Remaining: 0, Reset: 3 * time.Second, RetryAfter: time.Second},
+		},
+		{
+			name: "partial refill is not enough", tokens: 0, elapsed: 500 * time.Millisecond, wantTokens: 0.5,
+			want: Result{Remaining: 0, Reset: 2500 * time.Millisecond, RetryAfter: 500 * time.Millisecond},
+		},
+		{
+			name: "refill gives a token", tokens: 0.5, elapsed: 500 * time.Millisecond, wantTokens: 0,
+			want: Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second},
+		},
+		{
+			name: "refill stops at capacity", tokens: 0, elapsed: time.Hour, wantTokens: 2,
+			want: Result{Allowed: true, Remaining: 2, Reset: time.Second},
+		},
+		{
+			name: "clock going back does not drain", tokens: 1, elapsed: -time.Minute, wantTokens: 0,
+			want: Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, result := bucket.take(tt.tokens, tt.elapsed)
+			if tokens != tt.wantTokens {
+				t.Errorf("tokens = %v, want %v", tokens, tt.wantTokens)
+			}
+			if *result != tt.want {
+				t.Errorf("result = %+v, want %+v", *result, tt.want)
+			}
+		})
+	}
+}
+
+// TestRuleBucket проверяет ёмкость и скорость корзины по правилу.
+func TestRuleBucket(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		want Bucket
+	}{
+		{"burst defaults to limit", Rule{Limit: 10, Period: time.Minute}, Bucket{Capacity: 10, Rate: 10.0 / 60}},
+		{"explicit burst", Rule{Limit: 10, Period: 10 * time.Second, Burst: 3}, Bucket{Capacity: 3, Rate: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.bucket(); got != tt.want {
+				t.Errorf("bucket = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMiddlewareBurst проверяет, что после Burst запросов подряд следует 429 с Retry-After,
+// а через время пополнения запрос снова проходит.
+func TestMiddlewareBurst(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	resolver, err := realip.NewResolver(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := New(store, []*Rule{
+		{Method: "POST", Route: "/login", Key: KeyIP, Limit: 6, Period: time.Minute, Burst: 2},
+	}, resolver, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.Use(limiter.Middleware)
+	router.HandleFunc("/login", func(writer http.ResponseWriter, request *http.Request) {}).Methods("POST")
+
+	steps := []struct {
+		advance    time.Duration
+		code       int
+		remaining  string
+		retryAfter string
+	}{
+		{0, http.StatusOK, "1", ""},
+		{0, http.StatusOK, "0", ""},
+		{0, http.StatusTooManyRequests, "0", "10"},
+		{4 * time.Second, http.StatusTooManyRequests, "0", "6"},
+		{6 * time.Second, http.StatusOK, "0", ""},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		request := httptest.NewRequest("POST", "/login", nil)
+		request.RemoteAddr = "192.0.2.1:1234"
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != step.code {
+			t.Errorf("step %d: code = %d, want %d", i, recorder.Code, step.code)
+		}
+		if got := recorder.Header().Get("RateLimit-Remaining"); got != step.remaining {
+			t.Errorf("step %d: RateLimit-Remaining = %q, want %q", i, got, step.remaining)
+		}
+		if got := recorder.Header().Get("Retry-After"); got != step.retryAfter {
+			t.Errorf("step %d: Retry-After = %q, want %q", i, got, step.retryAfter)
+		}
+	}
+
+	// другой адрес считается отдельно
+	request := httptest.NewRequest("POST", "/login", nil)
+	request.RemoteAddr = "192.0.2.2:1234"
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("other client: code = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
+
+// TestKeyManagerIgnoresBasicLogin проверяет, что непроверенный логин Basic не меняет ключ.
+func TestKeyManagerIgnoresBasicLogin(t *testing.T) {
+	resolver, err := realip.NewResolver(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := New(NewMemoryStore(), nil, resolver, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := httptest.NewRequest("POST", "/rates", nil)
+	request.RemoteAddr = "192.0.2.1:1234"
+	request.SetBasicAuth("fake-login", "wrong")
+
+	if got := limiter.key(request, &Rule{Key: KeyManager}); got != "ip:192.0.2.1" {
+		t.Errorf("key = %q, want %q", got, "ip:192.0.2.1")
+	}
+}
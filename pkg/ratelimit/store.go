@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrInternal возвращается, когда происходит внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// cleanupEvery - через сколько обращений память чистится от наполнившихся корзин.
+const cleanupEvery = 10000
+
+// MemoryStore хранит корзины в памяти процесса.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+	now     func() time.Time
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// NewMemoryStore - конструктор.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket), now: time.Now}
+}
+
+// Take реализует Store.
+func (s *MemoryStore) Take(ctx context.Context, key string, bucket Bucket) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.takes++
+	if s.takes%cleanupEvery == 0 {
+		for key, item := range s.buckets {
+			if now.After(item.full) {
+				delete(s.buckets, key)
+			}
+		}
+	}
+
+	item, ok := s.buckets[key]
+	if !ok {
+		item = &memoryBucket{tokens: bucket.Capacity, updated: now}
+		s.buckets[key] = item
+	}
+	tokens, result := bucket.take(item.tokens, now.Sub(item.updated))
+	item.tokens, item.updated, item.full = tokens, now, now.Add(result.Reset)
+	return result, nil
+}
+
+// PostgresStore хранит корзины в таблице rate_limits, общей для всех экземпляров.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore - конструктор.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+// Take реализует Store. Время берётся из БД, чтобы часы экземпляров не влияли на результат.
+func (s *PostgresStore) Take(ctx context.Context, key string, bucket Bucket) (result *Result, err error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+			return
+		}
+		err = tx.Commit(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO rate_limits (key, tokens, full_at) VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO NOTHING
+	`, key, bucket.Capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens, elapsed float64
+	err = tx.QueryRow(ctx, `
+		SELECT tokens, EXTRACT(EPOCH FROM clock_timestamp() - updated)
+		FROM rate_limits WHERE key = $1 FOR UPDATE
+	`, key).Scan(&tokens, &elapsed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInternal
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, result = bucket.take(tokens, time.Duration(elapsed*float64(time.Second)))
+	_, err = tx.Exec(ctx, `
+		UPDATE rate_limits SET tokens = $2, updated = clock_timestamp(), full_at = clock_timestamp() + $3 * INTERVAL '1 second'
+		WHERE key = $1
+	`, key, tokens, result.Reset.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Purge удаляет корзины, которые уже наполнились: они ничем не отличаются от отсутствующих.
+func (s *PostgresStore) Purge(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM rate_limits WHERE full_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
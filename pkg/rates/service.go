@@ -0,0 +1,262 @@
+package rates
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/money"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNoRate возвращается, когда на дату нет курса для пары валют.
+var ErrNoRate = errors.New("no exchange rate")
+
+// ErrInvalidRate возвращается, когда курс задан неверно.
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// dateLayout - формат даты курса.
+const dateLayout = "2006-01-02"
+
+// Service описывает сервис курсов валют.
+type Service struct {
+	pool *pgxpool.Pool
+}
+
+// NewService создаёт сервис.
+func NewService(pool *pgxpool.Pool) *Service {
+	return &Service{pool: pool}
+}
+
+// Rate - курс валюты Base к валюте Quote на дату Date: за одну единицу Base дают Rate единиц Quote.
+// Spread - маржа банка в процентах, на которую уменьшается сумма при конвертации.
+type Rate struct {
+	Base   string    `json:"base"`
+	Quote  string    `json:"quote"`
+	Rate   float64   `json:"rate"`
+	Spread float64   `json:"spread"`
+	Date   time.Time `json:"date"`
+}
+
+// validate проверяет курс и приводит коды валют к верхнему регистру.
+func (r *Rate) validate() error {
+	base, err := money.Lookup(r.Base)
+	if err != nil {
+		return err
+	}
+	quote, err := money.Lookup(r.Quote)
+	if err != nil {
+		return err
+	}
+	// NaN не меньше и не больше нуля, поэтому бесконечные и нечисловые значения
+	// (strconv.ParseFloat принимает "NaN" и "Inf") отсекаются отдельно
+	if !finite(r.Rate) || !finite(r.Spread) {
+		return ErrInvalidRate
+	}
+	if base.Code == quote.Code || r.Rate <= 0 || r.Spread < 0 || r.Spread >= 100 {
+		return ErrInvalidRate
+	}
+	r.Base, r.Quote = base.Code, quote.Code
+	if r.Date.IsZero() {
+		r.Date = time.Now()
+	}
+	r.Date = time.Date(r.Date.Year(), r.Date.Month(), r.Date.Day(), 0, 0, 0, 0, time.UTC)
+	return nil
+}
+
+// finite возвращает true для конечного числа.
+func finite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// Save сохраняет курс. Повторный курс той же пары на ту же дату заменяет прежний.
+func (s *Service) Save(ctx context.Context, item *Rate) (*Rate, error) {
+	err := item.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO exchange_rates(base, quote, rate, spread, date) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (base, quote, date) DO UPDATE SET rate = excluded.rate, spread = excluded.spread
+	`, item.Base, item.Quote, item.Rate, item.Spread, item.Date)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return item, nil
+}
+
+// Load загружает курсы из CSV со строками "date,base,quote,rate[,spread]" в одной транзакции.
+// Строки, начинающиеся с #, и строка заголовка пропускаются. Возвращает число загруженных курсов.
+func (s *Service) Load(ctx context.Context, reader io.Reader) (int, error) {
+	records := csv.NewReader(reader)
+	records.Comment = '#'
+	records.FieldsPerRecord = -1
+
+	items := make([]*Rate, 0)
+	for line := 1; ; line++ {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidRate, err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+
+		item, err := parseRecord(record)
+		if err != nil {
+			return 0, fmt.Errorf("%w: line %d: %v", ErrInvalidRate, line, err)
+		}
+		items = append(items, item)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	for _, item := range items {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO exchange_rates(base, quote, rate, spread, date) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (base, quote, date) DO UPDATE SET rate = excluded.rate, spread = excluded.spread
+		`, item.Base, item.Quote, item.Rate, item.Spread, item.Date)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return 0, ErrInternal
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+
+	return len(items), nil
+}
+
+// parseRecord разбирает строку CSV с курсом.
+func parseRecord(record []string) (*Rate, error) {
+	if len(record) < 4 || len(record) > 5 {
+		return nil, errors.New("expected date,base,quote,rate[,spread]")
+	}
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+
+	date, err := time.Parse(dateLayout, record[0])
+	if err != nil {
+		return nil, err
+	}
+	item := &Rate{Base: record[1], Quote: record[2], Date: date}
+	item.Rate, err = strconv.ParseFloat(record[3], 64)
+	if err != nil {
+		return nil, err
+	}
+	if len(record) == 5 && record[4] != "" {
+		item.Spread, err = strconv.ParseFloat(record[4], 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = item.validate()
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// On возвращает последние известные на дату at курсы всех пар.
+func (s *Service) On(ctx context.Context, at time.Time) ([]*Rate, error) {
+	items := make([]*Rate, 0)
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (base, quote) base, quote, rate, spread, date FROM exchange_rates
+		WHERE date <= $1 ORDER BY base, quote, date DESC
+	`, at)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &Rate{}
+		err = rows.Scan(&item.Base, &item.Quote, &item.Rate, &item.Spread, &item.Date)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// Find возвращает курс base к quote, действующий на дату at.
+// Если есть только обратный курс, он обращается. Для одинаковых валют курс равен 1.
+func (s *Service) Find(ctx context.Context, base string, quote string, at time.Time) (*Rate, error) {
+	if base == quote {
+		return &Rate{Base: base, Quote: quote, Rate: 1, Date: at}, nil
+	}
+
+	item := &Rate{}
+	err := s.pool.QueryRow(ctx, `
+		SELECT base, quote, rate, spread, date FROM exchange_rates
+		WHERE ((base = $1 AND quote = $2) OR (base = $2 AND quote = $1)) AND date <= $3
+		ORDER BY date DESC, base = $1 DESC
+		LIMIT 1
+	`, base, quote, at).Scan(&item.Base, &item.Quote, &item.Rate, &item.Spread, &item.Date)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoRate
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	if item.Base != base {
+		item.Base, item.Quote, item.Rate = base, quote, 1/item.Rate
+	}
+	return item, nil
+}
+
+// Convert переводит сумму в валюту to по курсу на дату at и возвращает результат вместе с курсом.
+func (s *Service) Convert(ctx context.Context, amount money.Money, to string, at time.Time) (money.Money, *Rate, error) {
+	if !money.Valid(to) {
+		return money.Money{}, nil, money.ErrUnknownCurrency
+	}
+	rate, err := s.Find(ctx, amount.Currency, strings.ToUpper(to), at)
+	if err != nil {
+		return money.Money{}, nil, err
+	}
+
+	converted, err := amount.Convert(rate.Quote, rate.Rate, rate.Spread)
+	if err != nil {
+		return money.Money{}, nil, err
+	}
+	return converted, rate, nil
+}
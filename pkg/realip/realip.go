@@ -0,0 +1,88 @@
+package realip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidProxy возвращается, когда адрес доверенного прокси задан неверно.
+var ErrInvalidProxy = errors.New("invalid proxy address")
+
+// Resolver определяет адрес клиента с учётом доверенных прокси.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver создаёт Resolver. proxies - адреса (10.0.0.1) и подсети (10.0.0.0/8) прокси,
+// которым можно верить в X-Forwarded-For.
+func NewResolver(proxies []string) (*Resolver, error) {
+	trusted := make([]*net.IPNet, 0, len(proxies))
+	for _, value := range proxies {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, value)
+		}
+		trusted = append(trusted, network)
+	}
+	return &Resolver{trusted: trusted}, nil
+}
+
+// ClientIP возвращает адрес клиента. X-Forwarded-For учитывается, только если запрос пришёл
+// от доверенного прокси: адреса разбираются справа налево, пока не встретится недоверенный.
+func (r *Resolver) ClientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	if !r.trustedIP(host) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(request.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if ip == "" {
+			continue
+		}
+		if net.ParseIP(ip) == nil {
+			break
+		}
+		host = ip
+		if !r.trustedIP(ip) {
+			break
+		}
+	}
+	return host
+}
+
+func (r *Resolver) trustedIP(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package realip
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClientIP проверяет, что X-Forwarded-For учитывается только от доверенных прокси
+// и только до первого недоверенного адреса справа.
+func TestClientIP(t *testing.T) {
+	resolver, err := NewResolver([]string{"10.0.0.0/8", "192.0.2.10", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct client", "203.0.113.5:4000", nil, "203.0.113.5"},
+		{"untrusted peer can't spoof", "203.0.113.5:4000", []string{"198.51.100.1"}, "203.0.113.5"},
+		{"trusted proxy without header", "10.1.2.3:4000", nil, "10.1.2.3"},
+		{"trusted proxy", "10.1.2.3:4000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.1.2.3:4000", []string{"198.51.100.1, 10.0.0.7, 192.0.2.10"}, "198.51.100.1"},
+		{"spoofed left part is ignored", "10.1.2.3:4000", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"spoofed address behind an untrusted hop", "10.1.2.3:4000", []string{"10.9.9.9, 198.51.100.1, 10.0.0.7"}, "198.51.100.1"},
+		{"several headers", "10.1.2.3:4000", []string{"1.1.1.1", "198.51.100.1"}, "198.51.100.1"},
+		{"garbage stops the walk", "10.1.2.3:4000", []string{"198.51.100.1, not-an-ip, 10.0.0.7"}, "10.0.0.7"},
+		{"empty items are skipped", "10.1.2.3:4000", []string{"198.51.100.1,, "}, "198.51.100.1"},
+		{"all hops trusted", "10.1.2.3:4000", []string{"10.0.0.8, 10.0.0.7"}, "10.0.0.8"},
+		{"ipv6 proxy", "[2001:db8::1]:4000", []string{"2001:db8::abcd"}, "2001:db8::abcd"},
+		{"remote without port", "203.0.113.5", []string{"198.51.100.1"}, "203.0.113.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.RemoteAddr = tt.remote
+			for _, value := range tt.forwarded {
+				request.Header.Add("X-Forwarded-For", value)
+			}
+			if got := resolver.ClientIP(request); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNoTrustedProxies проверяет, что без доверенных прокси заголовок игнорируется.
+func TestNoTrustedProxies(t *testing.T) {
+	resolver, err := NewResolver(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := httptest.NewRequest("GET", "/", nil)
+	request.RemoteAddr = "10.1.2.3:4000"
+	request.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := resolver.ClientIP(request); got != "10.1.2.3" {
+		t.Errorf("ClientIP = %q, want %q", got, "10.1.2.3")
+	}
+}
+
+// TestNewResolverInvalid проверяет разбор неверных адресов прокси.
+func TestNewResolverInvalid(t *testing.T) {
+	for _, value := range []string{"10.0.0", "10.0.0.0/33", "proxy.local"} {
+		_, err := NewResolver([]string{value})
+		if !errors.Is(err, ErrInvalidProxy) {
+			t.Errorf("NewResolver(%q) error = %v, want ErrInvalidProxy", value, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package sales
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/az1zcheckit/crud/pkg/csvsafe"
+)
+
+// WriteManagersCSV записывает отчёт по менеджерам в формате CSV.
+// Имена и отделы экранируются от CSV-инъекции.
+func WriteManagersCSV(w io.Writer, items []*ManagerReport) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"manager_id", "name", "department", "salary", "plan", "sales", "count", "fulfilment", "bonus"})
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		err = writer.Write([]string{
+			strconv.FormatInt(item.ManagerID, 10),
+			csvsafe.Text(item.Name),
+			csvsafe.Text(item.Department),
+			strconv.Itoa(item.Salary),
+			strconv.FormatInt(item.Plan, 10),
+			strconv.FormatInt(item.Sales, 10),
+			strconv.FormatInt(item.Count, 10),
+			strconv.FormatFloat(item.Fulfilment, 'f', 2, 64),
+			strconv.FormatInt(item.Bonus, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteDepartmentsCSV записывает отчёт по отделам в формате CSV.
+func WriteDepartmentsCSV(w io.Writer, items []*DepartmentReport) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"department", "managers", "plan", "sales", "count", "fulfilment", "bonus"})
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		err = writer.Write([]string{
+			csvsafe.Text(item.Department),
+			strconv.Itoa(item.Managers),
+			strconv.FormatInt(item.Plan, 10),
+			strconv.FormatInt(item.Sales, 10),
+			strconv.FormatInt(item.Count, 10),
+			strconv.FormatFloat(item.Fulfilment, 'f', 2, 64),
+			strconv.FormatInt(item.Bonus, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,322 @@
+package sales
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/money"
+	"github.com/az1zcheckit/crud/pkg/rates"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrInternal возвращается, когда произошла внутренняя ошибка.
+var ErrInternal = errors.New("internal error")
+
+// ErrNoSuchManager возвращается, когда менеджер не найден или не активен.
+var ErrNoSuchManager = errors.New("no such manager")
+
+// ErrNoSuchCustomer возвращается, когда покупатель не найден.
+var ErrNoSuchCustomer = errors.New("no such customer")
+
+// ErrNoSuchProduct возвращается, когда продукт не найден в каталоге или сейчас не продаётся.
+var ErrNoSuchProduct = errors.New("no such product")
+
+// ErrInvalidSale возвращается, когда в продаже не указан товар или сумма.
+var ErrInvalidSale = errors.New("invalid sale")
+
+// ErrInvalidPeriod возвращается, когда начало периода позже его конца.
+var ErrInvalidPeriod = errors.New("invalid period")
+
+// BonusRate - процент оклада, выплачиваемый менеджеру за выполнение плана.
+const BonusRate = 20
+
+// OverPlanRate - процент от продаж сверх плана, добавляемый к бонусу.
+const OverPlanRate = 5
+
+// Service описывает сервис учёта продаж менеджеров.
+type Service struct {
+	pool     *pgxpool.Pool
+	ratesSvc *rates.Service
+}
+
+// NewService создаёт сервис.
+func NewService(pool *pgxpool.Pool, ratesSvc *rates.Service) *Service {
+	return &Service{pool: pool, ratesSvc: ratesSvc}
+}
+
+// Sale представляет продажу товара покупателю менеджером.
+// Amount, как и план менеджера, задаётся в money.Default.
+// Если указан ProductID, название (и, при нулевой сумме, цена) берутся из каталога;
+// цена в другой валюте пересчитывается по текущему курсу.
+type Sale struct {
+	ID         int64     `json:"id"`
+	ManagerID  int64     `json:"managerId"`
+	CustomerID int64     `json:"customerId"`
+	ProductID  *int64    `json:"productId"`
+	Product    string    `json:"product"`
+	Amount     int64     `json:"amount"`
+	Created    time.Time `json:"created"`
+}
+
+// Period - полуинтервал [From, To), за который строится отчёт.
+type Period struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// CurrentMonth возвращает период текущего календарного месяца.
+func CurrentMonth(now time.Time) Period {
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return Period{From: from, To: from.AddDate(0, 1, 0)}
+}
+
+// Months возвращает количество календарных месяцев, которые затрагивает период (не меньше одного).
+// План менеджера задаётся на месяц, поэтому план за период равен Plan * Months.
+func (p Period) Months() int {
+	last := p.To.Add(-time.Nanosecond)
+	months := (last.Year()-p.From.Year())*12 + int(last.Month()-p.From.Month()) + 1
+	if months < 1 {
+		return 1
+	}
+	return months
+}
+
+// ManagerReport - выполнение плана менеджером за период.
+type ManagerReport struct {
+	ManagerID  int64   `json:"managerId"`
+	Name       string  `json:"name"`
+	Department string  `json:"department"`
+	Salary     int     `json:"salary"`
+	Plan       int64   `json:"plan"`
+	Sales      int64   `json:"sales"`
+	Count      int64   `json:"count"`
+	Fulfilment float64 `json:"fulfilment"`
+	Bonus      int64   `json:"bonus"`
+}
+
+// DepartmentReport - выполнение плана отделом за период.
+type DepartmentReport struct {
+	Department string  `json:"department"`
+	Managers   int     `json:"managers"`
+	Plan       int64   `json:"plan"`
+	Sales      int64   `json:"sales"`
+	Count      int64   `json:"count"`
+	Fulfilment float64 `json:"fulfilment"`
+	Bonus      int64   `json:"bonus"`
+}
+
+// fulfilment возвращает процент выполнения плана.
+func fulfilment(sales int64, plan int64) float64 {
+	if plan <= 0 {
+		return 0
+	}
+	return float64(sales) * 100 / float64(plan)
+}
+
+// bonus вычисляет бонус менеджера: BonusRate процентов оклада за выполненный план
+// плюс OverPlanRate процентов от продаж сверх плана.
+func bonus(salary int, plan int64, sales int64, months int) int64 {
+	if plan <= 0 || sales < plan {
+		return 0
+	}
+	return int64(salary)*int64(months)*BonusRate/100 + (sales-plan)*OverPlanRate/100
+}
+
+// Record сохраняет продажу. Продавать могут только активные менеджеры.
+func (s *Service) Record(ctx context.Context, item *Sale) (*Sale, error) {
+	if item.ProductID != nil {
+		var price money.Money
+		err := s.pool.QueryRow(ctx, `
+			SELECT name, price, currency FROM products
+			WHERE id = $1 AND active AND valid_from <= now() AND (valid_to IS NULL OR valid_to > now())
+		`, *item.ProductID).Scan(&item.Product, &price.Amount, &price.Currency)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoSuchProduct
+		}
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		if item.Amount == 0 {
+			converted, _, err := s.ratesSvc.Convert(ctx, price, money.Default, time.Now())
+			if err != nil {
+				logger.Ctx(ctx).Error(err)
+				return nil, ErrInvalidSale
+			}
+			item.Amount = converted.Amount
+		}
+	}
+	if item.Product == "" || item.Amount <= 0 {
+		return nil, ErrInvalidSale
+	}
+
+	var active bool
+	err := s.pool.QueryRow(ctx, `SELECT active FROM managers WHERE id = $1`, item.ManagerID).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !active) {
+		return nil, ErrNoSuchManager
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	var exists bool
+	err = s.pool.QueryRow(ctx, `SELECT true FROM customers WHERE id = $1`, item.CustomerID).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoSuchCustomer
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	res := &Sale{}
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO sales(manager_id, customer_id, product_id, product, amount) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, manager_id, customer_id, product_id, product, amount, created
+	`, item.ManagerID, item.CustomerID, item.ProductID, item.Product, item.Amount).Scan(
+		&res.ID, &res.ManagerID, &res.CustomerID, &res.ProductID, &res.Product, &res.Amount, &res.Created)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return res, nil
+}
+
+// ByManager возвращает продажи менеджера за период.
+func (s *Service) ByManager(ctx context.Context, managerID int64, period Period) ([]*Sale, error) {
+	if period.To.Before(period.From) {
+		return nil, ErrInvalidPeriod
+	}
+
+	items := make([]*Sale, 0)
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, manager_id, customer_id, product_id, product, amount, created FROM sales
+		WHERE manager_id = $1 AND created >= $2 AND created < $3 ORDER BY created
+	`, managerID, period.From, period.To)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &Sale{}
+		err = rows.Scan(&item.ID, &item.ManagerID, &item.CustomerID, &item.ProductID, &item.Product, &item.Amount, &item.Created)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// Managers возвращает отчёт о выполнении плана каждым активным менеджером за период.
+// Если department не пустой, в отчёт попадают только менеджеры этого отдела.
+func (s *Service) Managers(ctx context.Context, period Period, department string) ([]*ManagerReport, error) {
+	if period.To.Before(period.From) {
+		return nil, ErrInvalidPeriod
+	}
+	months := period.Months()
+
+	items := make([]*ManagerReport, 0)
+	rows, err := s.pool.Query(ctx, `
+		SELECT m.id, m.name, COALESCE(m.department, ''), m.salary, m.plan,
+			COALESCE(SUM(s.amount), 0), COUNT(s.id)
+		FROM managers m
+		LEFT JOIN sales s ON s.manager_id = m.id AND s.created >= $1 AND s.created < $2
+		WHERE m.active AND ($3 = '' OR m.department = $3)
+		GROUP BY m.id
+		ORDER BY m.id
+	`, period.From, period.To, department)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &ManagerReport{}
+		var plan int
+		err = rows.Scan(&item.ManagerID, &item.Name, &item.Department, &item.Salary, &plan, &item.Sales, &item.Count)
+		if err != nil {
+			logger.Ctx(ctx).Error(err)
+			return nil, ErrInternal
+		}
+		item.Plan = int64(plan) * int64(months)
+		item.Fulfilment = fulfilment(item.Sales, item.Plan)
+		item.Bonus = bonus(item.Salary, item.Plan, item.Sales, months)
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	return items, nil
+}
+
+// Departments возвращает отчёт о выполнении плана по отделам за период.
+func (s *Service) Departments(ctx context.Context, period Period) ([]*DepartmentReport, error) {
+	managers, err := s.Managers(ctx, period, "")
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*DepartmentReport)
+	items := make([]*DepartmentReport, 0)
+	for _, manager := range managers {
+		item, ok := byName[manager.Department]
+		if !ok {
+			item = &DepartmentReport{Department: manager.Department}
+			byName[manager.Department] = item
+			items = append(items, item)
+		}
+		item.Managers++
+		item.Plan += manager.Plan
+		item.Sales += manager.Sales
+		item.Count += manager.Count
+		item.Bonus += manager.Bonus
+	}
+	for _, item := range items {
+		item.Fulfilment = fulfilment(item.Sales, item.Plan)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Department < items[j].Department
+	})
+
+	return items, nil
+}
+
+// Top возвращает limit менеджеров с наибольшим процентом выполнения плана за период.
+func (s *Service) Top(ctx context.Context, period Period, limit int) ([]*ManagerReport, error) {
+	items, err := s.Managers(ctx, period, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Fulfilment != items[j].Fulfilment {
+			return items[i].Fulfilment > items[j].Fulfilment
+		}
+		return items[i].Sales > items[j].Sales
+	})
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+
+	return items, nil
+}
@@ -2,12 +2,19 @@ package security
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
-	"log"
+	"strings"
 	"time"
 
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/az1zcheckit/crud/pkg/tracing"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // ErrNoSuchUser если пользователь не найден
@@ -22,6 +29,12 @@ var ErrInternal = errors.New("internal error")
 // ErrExpiredToken возвращается когда чувачок исчерпал свой токен
 var ErrExpiredToken = errors.New("Token is expired")
 
+// ErrLoginExists возвращается, когда менеджер с таким логином уже есть.
+var ErrLoginExists = errors.New("login already exists")
+
+// SessionTTL - сколько действует сессия менеджера в веб-интерфейсе.
+const SessionTTL = 12 * time.Hour
+
 // Service описывает сервис работы с менеджерами.
 type Service struct {
 	pool *pgxpool.Pool
@@ -61,6 +74,9 @@ func (s *Service) AuthentificateCustomer(
 	ctx context.Context,
 	token string,
 ) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "security.AuthentificateCustomer")
+	defer span.End()
+
 	err = s.pool.QueryRow(ctx, `SELECT customer_id FROM customers_tokens WHERE token = $1`, token).Scan(&id)
 
 	if err == pgx.ErrNoRows {
@@ -77,12 +93,15 @@ func (s Service) AuthForCustomer(
 	ctx context.Context,
 	token string,
 ) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "security.AuthForCustomer")
+	defer span.End()
+
 	// Время когда исчерпается авторизация
 	expiredTime := time.Now()
 	nowTimeInSec := expiredTime.UnixNano()
 	err = s.pool.QueryRow(ctx, `SELECT customer_id, expire FROM customers_tokens WHERE token = $1`, token).Scan(&id, &expiredTime)
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return 0, ErrNoSuchUser
 	}
 
@@ -92,14 +111,28 @@ func (s Service) AuthForCustomer(
 	return id, nil
 }
 
-// Auth - метод авторизации.
-func (s *Service) Auth(login, password string) bool {
+// ManagerIDByLogin возвращает id активного менеджера по логину.
+// Если менеджер не найден или неактивен, возвращается ErrNoSuchUser.
+func (s *Service) ManagerIDByLogin(ctx context.Context, login string) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "security.ManagerIDByLogin")
+	defer span.End()
 
-	log.Print("Go to func Auth")
-	log.Print(login, password)
+	err = s.pool.QueryRow(ctx, `SELECT id FROM managers WHERE login = $1 AND active`, login).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNoSuchUser
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+	return id, nil
+}
 
+// Auth - метод авторизации.
+func (s *Service) Auth(login, password string) bool {
 	pass := ""
-	ctx := context.Background()
+	ctx, span := tracing.Start(context.Background(), "security.Auth")
+	defer span.End()
 	err := s.pool.QueryRow(ctx, `
 		SELECT password FROM managers WHERE login = $1
 	`, login).Scan(&pass)
@@ -109,13 +142,209 @@ func (s *Service) Auth(login, password string) bool {
 	}
 
 	if err != nil {
-		log.Print(err)
+		logger.Ctx(ctx).Error(err)
 		return false
 	}
 
-	log.Print(pass)
-	if pass == password {
-		return true
+	return checkPassword(pass, password)
+}
+
+// checkPassword сравнивает пароль с сохранённым: хешем bcrypt
+// или, для старых записей, открытым текстом.
+func checkPassword(stored string, password string) bool {
+	if strings.HasPrefix(stored, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+// AuthManager проверяет логин и пароль активного менеджера и возвращает его id.
+//
+//	Если менеджер не найден или неактивен, возвращается ErrNoSuchUser.
+//	Если пароль не верен, возвращается ErrInvalidPassword.
+//	Если происходит другая ошибка, возвращается ErrInternal.
+func (s *Service) AuthManager(ctx context.Context, login string, password string) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "security.AuthManager")
+	defer span.End()
+
+	var stored string
+	err = s.pool.QueryRow(ctx, `SELECT id, password FROM managers WHERE login = $1 AND active`, login).Scan(&id, &stored)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNoSuchUser
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+	if !checkPassword(stored, password) {
+		return 0, ErrInvalidPassword
+	}
+	return id, nil
+}
+
+// LoginManager проверяет логин и пароль менеджера и открывает сессию,
+// возвращая её токен для cookie. Ошибки - как у AuthManager.
+func (s *Service) LoginManager(ctx context.Context, login string, password string) (token string, err error) {
+	ctx, span := tracing.Start(ctx, "security.LoginManager")
+	defer span.End()
+
+	id, err := s.AuthManager(ctx, login, password)
+	if err != nil {
+		return "", err
+	}
+
+	buffer := make([]byte, 32)
+	_, err = rand.Read(buffer)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return "", ErrInternal
+	}
+	token = hex.EncodeToString(buffer)
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO managers_sessions(token, manager_id, expire) VALUES ($1, $2, $3)
+	`, token, id, time.Now().Add(SessionTTL))
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return "", ErrInternal
+	}
+	return token, nil
+}
+
+// ManagerBySession возвращает менеджера по токену сессии.
+//
+//	Если сессии нет или менеджер неактивен, возвращается ErrNoSuchUser.
+//	Если сессия истекла, возвращается ErrExpiredToken.
+func (s *Service) ManagerBySession(ctx context.Context, token string) (*Managers, error) {
+	ctx, span := tracing.Start(ctx, "security.ManagerBySession")
+	defer span.End()
+
+	item := &Managers{}
+	var expire time.Time
+	err := s.pool.QueryRow(ctx, `
+		SELECT m.id, m.name, m.login, s.expire
+		FROM managers_sessions s JOIN managers m ON m.id = s.manager_id
+		WHERE s.token = $1 AND m.active
+	`, token).Scan(&item.ID, &item.Name, &item.Login, &expire)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoSuchUser
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	if time.Now().After(expire) {
+		return nil, ErrExpiredToken
+	}
+	return item, nil
+}
+
+// LogoutManager закрывает сессию менеджера.
+func (s *Service) LogoutManager(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "security.LogoutManager")
+	defer span.End()
+
+	_, err := s.pool.Exec(ctx, `DELETE FROM managers_sessions WHERE token = $1`, token)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// PurgeSessions удаляет истёкшие сессии менеджеров и возвращает их число.
+func (s *Service) PurgeSessions(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "security.PurgeSessions")
+	defer span.End()
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM managers_sessions WHERE expire < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return 0, ErrInternal
+	}
+	return tag.RowsAffected(), nil
+}
+
+// CreateManager добавляет активного менеджера с паролем password (хранится хеш bcrypt).
+//
+//	Если логин занят, возвращается ErrLoginExists.
+//	Если происходит другая ошибка, возвращается ErrInternal.
+func (s *Service) CreateManager(ctx context.Context, item *Managers, password string) (*Managers, error) {
+	ctx, span := tracing.Start(ctx, "security.CreateManager")
+	defer span.End()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+
+	var exists bool
+	err = s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM managers WHERE login = $1)`, item.Login).Scan(&exists)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	if exists {
+		return nil, ErrLoginExists
+	}
+
+	res := &Managers{}
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO managers(name, login, password, salary, plan, department) VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		RETURNING id, name, login, salary, plan, COALESCE(department, ''), active, created
+	`, item.Name, item.Login, string(hash), item.Salary, item.Plan, item.Department).Scan(
+		&res.ID, &res.Name, &res.Login, &res.Salary, &res.Plan, &res.Department, &res.Active, &res.Created)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, ErrLoginExists
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return nil, ErrInternal
+	}
+	return res, nil
+}
+
+// SetPassword задаёт менеджеру с логином login новый пароль и закрывает его сессии.
+//
+//	Если менеджер не найден, возвращается ErrNoSuchUser.
+//	Если происходит другая ошибка, возвращается ErrInternal.
+func (s *Service) SetPassword(ctx context.Context, login string, password string) error {
+	ctx, span := tracing.Start(ctx, "security.SetPassword")
+	defer span.End()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, `UPDATE managers SET password = $2 WHERE login = $1 RETURNING id`, login, string(hash)).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoSuchUser
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM managers_sessions WHERE manager_id = $1`, id)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return ErrInternal
 	}
-	return false
+	return nil
 }
@@ -0,0 +1,279 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+)
+
+const (
+	queueSize     = 4096
+	batchSize     = 512
+	flushInterval = 2 * time.Second
+)
+
+// ServiceName - имя сервиса в экспортируемых спанах.
+const ServiceName = "crud"
+
+// SpanData - завершённый спан в виде, удобном для экспорта.
+type SpanData struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Kind       Kind                   `json:"kind"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Duration   float64                `json:"duration_ms"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+func (s *Span) data() *SpanData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := &SpanData{
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		Name:       s.name,
+		Kind:       s.kind,
+		Start:      s.start,
+		End:        s.end,
+		Duration:   float64(s.end.Sub(s.start).Microseconds()) / 1000,
+		Attributes: s.attrs,
+		Error:      s.err,
+	}
+	if s.parent != (SpanID{}) {
+		data.ParentID = s.parent.String()
+	}
+	return data
+}
+
+// Exporter отправляет пачку завершённых спанов.
+type Exporter interface {
+	Export(ctx context.Context, spans []*SpanData) error
+}
+
+// Run собирает завершённые спаны в пачки и передаёт их экспортёру,
+// пока ctx не отменён; оставшиеся спаны отправляются перед выходом.
+// Запускается как фоновая задача.
+func (t *Tracer) Run(ctx context.Context) {
+	if !t.Enabled() {
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SpanData, 0, batchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			logger.Ctx(ctx).Warnw("can't export spans", "error", err, "spans", len(batch))
+		}
+		if dropped := atomic.SwapUint64(&t.dropped, 0); dropped > 0 {
+			logger.Ctx(ctx).Warnw("spans dropped, export queue is full", "spans", dropped)
+		}
+		batch = make([]*SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span.data())
+			if len(batch) >= batchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			for len(t.queue) > 0 {
+				batch = append(batch, (<-t.queue).data())
+			}
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			flush(flushCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// WriterExporter пишет спаны в JSON, по одному на строку (например, в stdout).
+type WriterExporter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewWriterExporter - конструктор.
+func NewWriterExporter(out io.Writer) *WriterExporter {
+	return &WriterExporter{out: out}
+}
+
+// Export пишет спаны.
+func (e *WriterExporter) Export(ctx context.Context, spans []*SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	encoder := json.NewEncoder(e.out)
+	for _, span := range spans {
+		if err := encoder.Encode(span); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OTLPExporter отправляет спаны по OTLP/HTTP в формате JSON,
+// например в локальный OpenTelemetry Collector: http://localhost:4318/v1/traces.
+type OTLPExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewOTLPExporter - конструктор.
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	return &OTLPExporter{endpoint: endpoint, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// ErrExport возвращается, когда коллектор не принял спаны.
+var ErrExport = errors.New("export failed")
+
+// Export отправляет спаны.
+func (e *OTLPExporter) Export(ctx context.Context, spans []*SpanData) error {
+	data, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: collector responded %s", ErrExport, response.Status)
+	}
+	return nil
+}
+
+// Структуры OTLP/JSON (opentelemetry-proto, ExportTraceServiceRequest).
+type (
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              Kind           `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+	}
+)
+
+func otlpRequest(spans []*SpanData) *otlpTraces {
+	items := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		item := otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentID,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		if span.Error != "" {
+			item.Status = otlpStatus{Code: 2, Message: span.Error}
+		}
+		items = append(items, item)
+	}
+
+	return &otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": ServiceName})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: ServiceName}, Spans: items}},
+	}}}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		var value otlpValue
+		switch v := attrs[key].(type) {
+		case bool:
+			value.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		result = append(result, otlpKeyValue{Key: key, Value: value})
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/az1zcheckit/crud/pkg/logger"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// TraceparentHeader - заголовок W3C Trace Context.
+const TraceparentHeader = "traceparent"
+
+// Middleware - middleware для mux.Router.Use: начинает серверный спан с именем
+// по шаблону маршрута, продолжая трассу из заголовка traceparent,
+// и добавляет trace_id в записи журнала запроса.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := request.Context()
+		if parent, ok := ParseTraceparent(request.Header.Get(TraceparentHeader)); ok {
+			ctx = WithRemote(ctx, parent)
+		}
+
+		route := request.URL.Path
+		if current := mux.CurrentRoute(request); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		ctx, span := StartKind(ctx, request.Method+" "+route, KindServer)
+		defer span.End()
+		span.SetAttribute("http.method", request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", request.URL.RequestURI())
+
+		if span.SpanContext().Sampled {
+			ctx = logger.WithContext(ctx, logger.FromContext(ctx).With(zap.String("trace_id", span.SpanContext().TraceID.String())))
+		}
+
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+		span.SetAttribute("http.status_code", recorder.status)
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetError(errorStatus(recorder.status))
+		}
+	})
+}
+
+// Inject добавляет traceparent текущего спана в заголовки исходящего запроса.
+func Inject(request *http.Request) {
+	sc := SpanContextFromContext(request.Context())
+	if sc.Valid() {
+		request.Header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+type errorStatus int
+
+func (e errorStatus) Error() string {
+	return http.StatusText(int(e))
+}
+
+// statusRecorder запоминает код ответа.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
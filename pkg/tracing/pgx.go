@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// PgxLogger - pgx.Logger, который превращает записи pgx о выполненных запросах
+// в клиентские спаны. pgx v4 сообщает о запросе после его завершения,
+// поэтому начало спана вычисляется по длительности. Аргументы запроса не записываются.
+// Для работы нужен ConnConfig.LogLevel не ниже pgx.LogLevelInfo.
+type PgxLogger struct{}
+
+// Log реализует pgx.Logger.
+func (PgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	sql, ok := data["sql"].(string)
+	if !ok {
+		return
+	}
+
+	end := time.Now()
+	elapsed, _ := data["time"].(time.Duration)
+	_, span := StartKind(ctx, "pgx "+msg, KindClient)
+	span.start = end.Add(-elapsed)
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.statement", sql)
+	if rows, ok := data["rowCount"].(int); ok {
+		span.SetAttribute("db.rows", rows)
+	}
+	if err, ok := data["err"].(error); ok {
+		span.SetError(err)
+	}
+	span.EndAt(end)
+}
@@ -0,0 +1,272 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind - вид спана, как в OpenTelemetry.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// TraceID - идентификатор трассы.
+type TraceID [16]byte
+
+// SpanID - идентификатор спана.
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// SpanContext - то, что передаётся между сервисами в заголовке traceparent.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// Valid проверяет, что идентификаторы не нулевые.
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Span - операция с началом и концом. Спан, который не попал в выборку, ничего не записывает,
+// но передаёт свой SpanContext дочерним спанам.
+type Span struct {
+	tracer *Tracer
+
+	sc       SpanContext
+	parent   SpanID
+	kind     Kind
+	start    time.Time
+	recorded bool
+	ended    int32
+
+	mu    sync.Mutex
+	name  string
+	end   time.Time
+	err   string
+	attrs map[string]interface{}
+}
+
+// SpanContext возвращает идентификаторы спана.
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// SetName меняет имя спана (например, когда стал известен шаблон маршрута).
+func (s *Span) SetName(name string) {
+	if !s.recorded {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttribute добавляет атрибут спана.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if !s.recorded {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = value
+}
+
+// SetError отмечает спан как завершившийся ошибкой.
+func (s *Span) SetError(err error) {
+	if !s.recorded || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End завершает спан и передаёт его экспортёру. Повторные вызовы игнорируются.
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt завершает спан в момент end.
+func (s *Span) EndAt(end time.Time) {
+	if !s.recorded || !atomic.CompareAndSwapInt32(&s.ended, 0, 1) {
+		return
+	}
+	s.mu.Lock()
+	s.end = end
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+type contextKey struct{ name string }
+
+var (
+	spanKey   = &contextKey{"span"}
+	remoteKey = &contextKey{"remote"}
+)
+
+// FromContext возвращает текущий спан или nil.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// SpanContextFromContext возвращает SpanContext текущего спана или удалённого родителя.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := FromContext(ctx); span != nil {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteKey).(SpanContext)
+	return sc
+}
+
+// WithRemote сохраняет в контексте родителя, пришедшего из другого сервиса.
+func WithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+var global atomic.Value
+
+// SetTracer задаёт трассировщик, которым пользуются Start и middleware.
+func SetTracer(tracer *Tracer) {
+	global.Store(tracer)
+}
+
+// Start начинает дочерний спан текущего спана из ctx:
+//
+//	ctx, span := tracing.Start(ctx, "customers.ByID")
+//	defer span.End()
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return StartKind(ctx, name, KindInternal)
+}
+
+// StartKind - то же, что Start, с указанием вида спана.
+func StartKind(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	tracer, _ := global.Load().(*Tracer)
+	return tracer.Start(ctx, name, kind)
+}
+
+// Tracer создаёт спаны и передаёт завершённые в экспортёр.
+type Tracer struct {
+	ratio    float64
+	exporter Exporter
+	queue    chan *Span
+	dropped  uint64
+}
+
+// NewTracer создаёт трассировщик. ratio - доля трасс, попадающих в выборку,
+// если решение не принял вызывающий сервис. Если exporter = nil, спаны не записываются.
+func NewTracer(exporter Exporter, ratio float64) *Tracer {
+	return &Tracer{ratio: ratio, exporter: exporter, queue: make(chan *Span, queueSize)}
+}
+
+// Enabled - записываются ли спаны.
+func (t *Tracer) Enabled() bool {
+	return t != nil && t.exporter != nil
+}
+
+// Start начинает спан. Работает и с nil-трассировщиком: тогда спан ничего не записывает.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	span := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+
+	if parent.Valid() {
+		span.sc.TraceID = parent.TraceID
+		span.sc.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		span.sc.TraceID = newTraceID()
+		span.sc.Sampled = t.sample()
+	}
+	span.sc.SpanID = newSpanID()
+	span.recorded = t.Enabled() && span.sc.Sampled
+	if span.recorded {
+		span.attrs = make(map[string]interface{})
+	}
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+func (t *Tracer) sample() bool {
+	if !t.Enabled() || t.ratio <= 0 {
+		return false
+	}
+	if t.ratio >= 1 {
+		return true
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil {
+		return false
+	}
+	return float64(n.Int64())/math.MaxInt64 < t.ratio
+}
+
+func (t *Tracer) enqueue(span *Span) {
+	select {
+	case t.queue <- span:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// Traceparent форматирует заголовок W3C traceparent.
+func (sc SpanContext) Traceparent() string {
+	flags := 0
+	if sc.Sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent разбирает заголовок W3C traceparent: 00-<trace-id>-<parent-id>-<flags>.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, false
+	}
+	version, err := hex.DecodeString(value[0:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(value) != 55) {
+		return sc, false
+	}
+	if _, err = hex.Decode(sc.TraceID[:], []byte(value[3:35])); err != nil {
+		return sc, false
+	}
+	if _, err = hex.Decode(sc.SpanID[:], []byte(value[36:52])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(value[53:55])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.Valid()
+}
@@ -0,0 +1,39 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Значения задаются при сборке:
+//
+//	go build -ldflags "-X github.com/az1zcheckit/crud/pkg/version.Version=v1.2.0 -X github.com/az1zcheckit/crud/pkg/version.Commit=$(git rev-parse HEAD) -X github.com/az1zcheckit/crud/pkg/version.Date=2022-01-01T00:00:00Z" ./cmd
+//
+// Если Version не задана, она берётся из версии модуля, которую записывает go build.
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info - информация о сборке.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get возвращает информацию о текущей сборке.
+func Get() *Info {
+	info := &Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	return info
+}
@@ -0,0 +1,208 @@
+// Package xlsx пишет книгу Excel (Office Open XML) с одним листом построчно,
+// не держа строки в памяти: части книги пишутся в zip по порядку, лист - последним.
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MIME - Content-Type файла xlsx.
+const MIME = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// стили ячеек из styles.xml
+const (
+	styleDate   = 1
+	styleHeader = 2
+)
+
+// epoch - нулевой день дат Excel (с учётом ошибки 1900 года).
+var epoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// Writer пишет строки листа. Строки нужно завершить вызовом Close.
+type Writer struct {
+	zip  *zip.Writer
+	buf  *bufio.Writer
+	rows int
+}
+
+// NewWriter начинает книгу с листом sheet в w.
+func NewWriter(w io.Writer, sheet string) (*Writer, error) {
+	archive := zip.NewWriter(w)
+	name, err := escape(sheet)
+	if err != nil {
+		return nil, err
+	}
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", fmt.Sprintf(workbook, name)},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+		{"xl/styles.xml", styles},
+	}
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.WriteString(file, xml.Header+part.body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+	_, err = buf.WriteString(xml.Header + `<worksheet xmlns="` + mainNS + `"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{zip: archive, buf: buf}, nil
+}
+
+// WriteHeader пишет строку заголовков жирным шрифтом.
+func (w *Writer) WriteHeader(names ...string) error {
+	values := make([]interface{}, len(names))
+	for i, name := range names {
+		values[i] = name
+	}
+	return w.write(values, styleHeader)
+}
+
+// WriteRow пишет строку. Поддерживаются строки, целые и дробные числа,
+// bool и time.Time (дата и время без часового пояса); nil - пустая ячейка.
+func (w *Writer) WriteRow(values ...interface{}) error {
+	return w.write(values, 0)
+}
+
+// Close завершает лист и книгу; w, переданный в NewWriter, не закрывается.
+func (w *Writer) Close() error {
+	_, err := w.buf.WriteString(`</sheetData></worksheet>`)
+	if err != nil {
+		return err
+	}
+	err = w.buf.Flush()
+	if err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+func (w *Writer) write(values []interface{}, style int) error {
+	w.rows++
+	row := strconv.Itoa(w.rows)
+	_, err := w.buf.WriteString(`<row r="` + row + `">`)
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		attrs := ` r="` + column(i) + row + `"`
+		if style != 0 {
+			attrs += ` s="` + strconv.Itoa(style) + `"`
+		}
+		var cell string
+		switch value := value.(type) {
+		case string:
+			text, err := escape(value)
+			if err != nil {
+				return err
+			}
+			cell = `<c` + attrs + ` t="inlineStr"><is><t xml:space="preserve">` + text + `</t></is></c>`
+		case int:
+			cell = `<c` + attrs + `><v>` + strconv.Itoa(value) + `</v></c>`
+		case int64:
+			cell = `<c` + attrs + `><v>` + strconv.FormatInt(value, 10) + `</v></c>`
+		case float64:
+			cell = `<c` + attrs + `><v>` + strconv.FormatFloat(value, 'g', -1, 64) + `</v></c>`
+		case bool:
+			v := "0"
+			if value {
+				v = "1"
+			}
+			cell = `<c` + attrs + ` t="b"><v>` + v + `</v></c>`
+		case time.Time:
+			if style == 0 {
+				attrs += ` s="` + strconv.Itoa(styleDate) + `"`
+			}
+			cell = `<c` + attrs + `><v>` + strconv.FormatFloat(serial(value), 'f', -1, 64) + `</v></c>`
+		default:
+			return fmt.Errorf("xlsx: unsupported value type %T", value)
+		}
+		_, err = w.buf.WriteString(cell)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.buf.WriteString(`</row>`)
+	return err
+}
+
+// column - буквенное имя колонки i (0 - A, 26 - AA).
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// serial - дата и время t (по часам его пояса) в днях от epoch.
+func serial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
+
+// escape экранирует text для XML; недопустимые в XML символы заменяются на U+FFFD.
+func escape(text string) (string, error) {
+	var builder strings.Builder
+	err := xml.EscapeText(&builder, []byte(text))
+	return builder.String(), err
+}
+
+const mainNS = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+
+const contentTypes = `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const rootRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const workbook = `<workbook xmlns="` + mainNS + `" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const workbookRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// styles: 0 - обычная ячейка, 1 - дата и время (встроенный формат 22), 2 - заголовок
+const styles = `<styleSheet xmlns="` + mainNS + `">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="22" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs>` +
+	`</styleSheet>`
@@ -0,0 +1,31 @@
+#!/bin/sh
+# Генерирует локальные сертификаты для проверки HTTPS и mTLS:
+#   ca.crt            - центр сертификации (tls.client_ca_file)
+#   server.crt/.key   - сертификат сервера для localhost (tls.cert_file, tls.key_file)
+#   <login>.crt/.key  - клиентский сертификат менеджера, CN = логин
+#
+#   ./scripts/gencerts.sh certs admin
+#   curl --cacert certs/ca.crt --cert certs/admin.crt --key certs/admin.key https://localhost:9999/customers
+set -eu
+
+dir=${1:-certs}
+login=${2:-admin}
+mkdir -p "$dir"
+cd "$dir"
+
+if [ ! -f ca.key ]; then
+	openssl req -x509 -newkey rsa:2048 -nodes -days 365 \
+		-subj "/CN=crud local CA" -keyout ca.key -out ca.crt
+fi
+
+openssl req -newkey rsa:2048 -nodes -subj "/CN=localhost" -keyout server.key -out server.csr
+printf "subjectAltName=DNS:localhost,IP:127.0.0.1\nextendedKeyUsage=serverAuth\n" > server.ext
+openssl x509 -req -days 365 -in server.csr -CA ca.crt -CAkey ca.key -CAcreateserial \
+	-extfile server.ext -out server.crt
+
+openssl req -newkey rsa:2048 -nodes -subj "/CN=$login" -keyout "$login.key" -out "$login.csr"
+printf "extendedKeyUsage=clientAuth\n" > client.ext
+openssl x509 -req -days 365 -in "$login.csr" -CA ca.crt -CAkey ca.key -CAcreateserial \
+	-extfile client.ext -out "$login.crt"
+
+rm -f server.csr server.ext "$login.csr" client.ext